@@ -30,6 +30,7 @@ import (
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/elasticsearch"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/grpcplugin"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/kafka"
+	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/objectstore"
 	kafkaRec "github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/receiver/kafka"
 	"github.com/jaegertracing/jaeger/ports"
 )
@@ -122,6 +123,9 @@ func createExporters(storageTypes string, factories config.Factories) (configmod
 		case "grpc-plugin":
 			grpcEx := factories.Exporters[grpcplugin.TypeStr].CreateDefaultConfig()
 			exporters[grpcplugin.TypeStr] = grpcEx
+		case "objectstore":
+			objectStore := factories.Exporters[objectstore.TypeStr].CreateDefaultConfig()
+			exporters[objectstore.TypeStr] = objectStore
 		default:
 			return nil, fmt.Errorf("unknown storage type: %s", s)
 		}
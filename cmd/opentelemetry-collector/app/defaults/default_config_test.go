@@ -38,6 +38,7 @@ import (
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/elasticsearch"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/grpcplugin"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/kafka"
+	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/objectstore"
 	kafkaRec "github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/receiver/kafka"
 	jConfig "github.com/jaegertracing/jaeger/pkg/config"
 	"github.com/jaegertracing/jaeger/ports"
@@ -89,6 +90,18 @@ func TestDefaultCollectorConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			storageType:    "objectstore",
+			zipkinHostPort: disabledHostPort,
+			exporterTypes:  []string{objectstore.TypeStr},
+			pipeline: configmodels.Pipelines{
+				"traces": {
+					InputType: configmodels.TracesDataType,
+					Receivers: []string{"jaeger"},
+					Exporters: []string{objectstore.TypeStr},
+				},
+			},
+		},
 		{
 			storageType:    "cassandra,elasticsearch,grpc-plugin",
 			zipkinHostPort: disabledHostPort,
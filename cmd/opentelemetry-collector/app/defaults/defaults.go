@@ -32,6 +32,7 @@ import (
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/grpcplugin"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/jaegerexporter"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/kafka"
+	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/objectstore"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/processor/resourceprocessor"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/receiver/jaegerreceiver"
 	kafkaRec "github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/receiver/kafka"
@@ -40,6 +41,7 @@ import (
 	storageEs "github.com/jaegertracing/jaeger/plugin/storage/es"
 	storageGrpc "github.com/jaegertracing/jaeger/plugin/storage/grpc"
 	storageKafka "github.com/jaegertracing/jaeger/plugin/storage/kafka"
+	storageObjectStore "github.com/jaegertracing/jaeger/plugin/storage/objectstore"
 )
 
 // Components creates default and Jaeger factories
@@ -68,6 +70,11 @@ func Components(v *viper.Viper) config.Factories {
 		opts.InitFromViper(v)
 		return opts
 	}}
+	objectStoreExp := &objectstore.Factory{OptionsFactory: func() *storageObjectStore.Options {
+		opts := objectstore.DefaultOptions()
+		opts.InitFromViper(v)
+		return opts
+	}}
 	kafkaRec := &kafkaRec.Factory{OptionsFactory: func() *ingesterApp.Options {
 		opts := kafkaRec.DefaultOptions()
 		opts.InitFromViper(v)
@@ -79,6 +86,7 @@ func Components(v *viper.Viper) config.Factories {
 	factories.Exporters[cassandraExp.Type()] = cassandraExp
 	factories.Exporters[esExp.Type()] = esExp
 	factories.Exporters[grpcExp.Type()] = grpcExp
+	factories.Exporters[objectStoreExp.Type()] = objectStoreExp
 	factories.Receivers[kafkaRec.Type()] = kafkaRec
 
 	jaegerRec := factories.Receivers["jaeger"].(*otelJaegerReceiver.Factory)
@@ -111,6 +119,7 @@ func addDefaultValuesToViper(v *viper.Viper) {
 	kafka.DefaultOptions().AddFlags(flagSet)
 	elasticsearch.DefaultOptions().AddFlags(flagSet)
 	cassandra.DefaultOptions().AddFlags(flagSet)
+	objectstore.DefaultOptions().AddFlags(flagSet)
 	pflagSet := &pflag.FlagSet{}
 	pflagSet.AddGoFlagSet(flagSet)
 	v.BindPFlags(pflagSet)
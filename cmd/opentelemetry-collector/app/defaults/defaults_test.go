@@ -24,6 +24,7 @@ import (
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/grpcplugin"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/jaegerexporter"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/kafka"
+	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/objectstore"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/receiver/jaegerreceiver"
 	kafkaRec "github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/receiver/kafka"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/receiver/zipkinreceiver"
@@ -35,12 +36,14 @@ func TestComponents(t *testing.T) {
 		kafka.DefaultOptions().AddFlags,
 		cassandra.DefaultOptions().AddFlags,
 		elasticsearch.DefaultOptions().AddFlags,
+		objectstore.DefaultOptions().AddFlags,
 	)
 	factories := Components(v)
 	assert.IsType(t, &kafka.Factory{}, factories.Exporters[kafka.TypeStr])
 	assert.IsType(t, &cassandra.Factory{}, factories.Exporters[cassandra.TypeStr])
 	assert.IsType(t, &elasticsearch.Factory{}, factories.Exporters[elasticsearch.TypeStr])
 	assert.IsType(t, &grpcplugin.Factory{}, factories.Exporters[grpcplugin.TypeStr])
+	assert.IsType(t, &objectstore.Factory{}, factories.Exporters[objectstore.TypeStr])
 	assert.IsType(t, &jaegerreceiver.Factory{}, factories.Receivers["jaeger"])
 	assert.IsType(t, &jaegerexporter.Factory{}, factories.Exporters["jaeger"])
 	assert.IsType(t, &kafkaRec.Factory{}, factories.Receivers[kafkaRec.TypeStr])
@@ -55,4 +58,7 @@ func TestComponents(t *testing.T) {
 	esFactory := factories.Exporters[elasticsearch.TypeStr]
 	ec := esFactory.CreateDefaultConfig().(*elasticsearch.Config)
 	assert.Equal(t, []string{"http://127.0.0.1:9200"}, ec.GetPrimary().Servers)
+	objectStoreFactory := factories.Exporters[objectstore.TypeStr]
+	oc := objectStoreFactory.CreateDefaultConfig().(*objectstore.Config)
+	assert.Equal(t, "us-east-1", oc.S3.Region)
 }
@@ -24,6 +24,7 @@ import (
 	"go.opentelemetry.io/collector/config"
 
 	"github.com/jaegertracing/jaeger/cmd/flags"
+	storageOtelExporter "github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter"
 	jConfig "github.com/jaegertracing/jaeger/pkg/config"
 	"github.com/jaegertracing/jaeger/plugin/storage/cassandra"
 )
@@ -85,4 +86,10 @@ func TestLoadConfigAndFlags(t *testing.T) {
 	assert.Equal(t, time.Second*12, cfg.SpanStoreWriteCacheTTL)
 	assert.Equal(t, true, cfg.Primary.TLS.Enabled)
 	assert.Equal(t, "/foo/bar", cfg.Primary.TLS.CAPath)
+	assert.Equal(t, true, cfg.SpanWriter.DropInternalSpans)
+	assert.Equal(t, 10, cfg.SpanWriter.MaxProcessTags)
+	assert.Equal(t, 72*time.Hour, cfg.SpanWriter.DropBeyondRetention)
+	assert.Equal(t, storageOtelExporter.DuplicateSpansDrop, cfg.SpanWriter.DuplicateSpans)
+	assert.Equal(t, map[string]string{"legacy-frontend": "frontend"}, cfg.SpanWriter.ServiceNameMap)
+	assert.Equal(t, map[string][]string{"frontend": {"hostname", "ip"}}, cfg.SpanWriter.RequiredProcessTags)
 }
@@ -0,0 +1,582 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/opentracing/opentracing-go/ext"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	jaegertranslator "go.opentelemetry.io/collector/translator/trace/jaeger"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+const (
+	baggageTagPrefix      = "baggage."
+	samplerProbabilityTag = "sampler.probability"
+	sourceLocationTag     = "source.location"
+	tagOrderTag           = "tag.order"
+	serviceInstanceIDAttr = "service.instance.id"
+	logRepeatField        = "repeat"
+	kindMismatchTag       = "jaeger.kind_mismatch"
+	peerServiceTag        = "peer.service"
+	messagingSystemAttr   = "messaging.system"
+	messagingDestAttr     = "messaging.destination"
+	rpcServiceAttr        = "rpc.service"
+	rpcMethodAttr         = "rpc.method"
+	droppedProcessTagsTag = "dropped_process_tags"
+	exceptionStackField   = "exception.stacktrace"
+	errorStackTag         = "error.stack"
+	codeFilepathAttribute = "code.filepath"
+	codeLinenoAttribute   = "code.lineno"
+	codeFunctionAttribute = "code.function"
+)
+
+// Causes of conversion failures reported in metrics.
+const (
+	conversionFailureNilTraceID      = "nil_trace_id"
+	conversionFailureNilSpanID       = "nil_span_id"
+	conversionFailureInvalidLength   = "invalid_length"
+	conversionFailureZeroTraceID     = "zero_trace_id"
+	conversionFailureZeroSpanID      = "zero_span_id"
+	conversionFailureInvalidParentID = "invalid_parent_span_id"
+	conversionFailureUnknown         = "unknown"
+)
+
+const (
+	traceIDLength = 16
+	spanIDLength  = 8
+)
+
+var conversionFailureCauses = []string{
+	conversionFailureNilTraceID,
+	conversionFailureNilSpanID,
+	conversionFailureInvalidLength,
+	conversionFailureZeroTraceID,
+	conversionFailureZeroSpanID,
+	conversionFailureInvalidParentID,
+	conversionFailureUnknown,
+}
+
+// converter translates OTEL traces to Jaeger model and applies conversion options.
+type converter struct {
+	baggageKeys            map[string]bool
+	samplingProbabilityKey string
+	sourceLocation         bool
+	tagOrder               bool
+	serviceNames           map[string]string
+	instanceTag            string
+	coalesceLogs           bool
+	kindMismatch           bool
+	normalizeDBStatement   bool
+	messagingPeerService   bool
+	rpcTags                bool
+	deterministicIDs       bool
+	processTagKeys         map[string]bool
+	moveProcessTags        bool
+	maxProcessTags         int
+	depth                  bool
+	probableRetryWindow    time.Duration
+	componentTag           bool
+	names                  *nameSanitizer
+	errorStack             bool
+	maxTagValueLength      int
+}
+
+func newConverter(opts Options) converter {
+	c := converter{
+		samplingProbabilityKey: opts.SamplingProbabilityTraceStateKey,
+		sourceLocation:         opts.SourceLocationTag,
+		tagOrder:               opts.TagOrderTag,
+		serviceNames:           lowercaseKeys(opts.ServiceNameMap),
+		instanceTag:            opts.InstanceTag,
+		coalesceLogs:           opts.CoalesceRepeatedLogs,
+		kindMismatch:           opts.KindMismatchTag,
+		normalizeDBStatement:   opts.NormalizeDBStatement,
+		messagingPeerService:   opts.MessagingPeerService,
+		rpcTags:                opts.RPCTags,
+		deterministicIDs:       opts.DeterministicIDs,
+		maxProcessTags:         opts.MaxProcessTags,
+		depth:                  opts.DepthTag,
+		probableRetryWindow:    opts.ProbableRetryWindow,
+		componentTag:           opts.ComponentTagFromLibrary,
+		errorStack:             opts.ErrorStackTag,
+		maxTagValueLength:      opts.MaxTagValueLength,
+	}
+	if opts.SanitizeNames {
+		c.names = newNameSanitizer(exporterMetricsFactory(opts))
+	}
+	if len(opts.BaggageKeys) > 0 {
+		c.baggageKeys = make(map[string]bool, len(opts.BaggageKeys))
+		for _, k := range opts.BaggageKeys {
+			c.baggageKeys[k] = true
+		}
+	}
+	if len(opts.ProcessTagKeys) > 0 {
+		c.processTagKeys = make(map[string]bool, len(opts.ProcessTagKeys))
+		for _, k := range opts.ProcessTagKeys {
+			c.processTagKeys[k] = true
+		}
+		c.moveProcessTags = opts.MoveTrimmedProcessTagsToSpans
+	}
+	return c
+}
+
+// convert translates traces to Jaeger batches.
+func (c converter) convert(td pdata.Traces) ([]*model.Batch, error) {
+	if c.deterministicIDs {
+		td = withDeterministicIDs(td)
+	}
+	batches, err := jaegertranslator.InternalTracesToJaegerProto(td)
+	if err != nil {
+		return nil, err
+	}
+	otlpSpans := c.otlpSpans(td)
+	var libraryNames []string
+	if c.componentTag {
+		libraryNames = instrumentationLibraryNames(td)
+	}
+	i := 0
+	for _, batch := range batches {
+		if batch.Process != nil {
+			if name, ok := c.serviceNames[strings.ToLower(batch.Process.ServiceName)]; ok {
+				batch.Process.ServiceName = name
+			}
+		}
+		if c.names != nil {
+			c.names.sanitizeService(batch.Process)
+		}
+		c.addInstanceTag(batch.Process)
+		trimmed := c.trimProcessTags(batch.Process)
+		c.limitProcessTags(batch.Process)
+		for _, span := range batch.Spans {
+			if c.moveProcessTags {
+				span.Tags = append(span.Tags, trimmed...)
+			}
+			if otlpSpans != nil {
+				c.convertOTLPSpan(span, otlpSpans[i])
+			}
+			if c.names != nil {
+				c.names.sanitizeOperation(span)
+			}
+			if libraryNames != nil {
+				addComponentTag(span, libraryNames[i])
+			}
+			c.convertSpan(span)
+			i++
+		}
+	}
+	if c.depth {
+		addDepthTags(batches)
+	}
+	if c.probableRetryWindow > 0 {
+		addProbableRetryTags(batches, c.probableRetryWindow)
+	}
+	return batches, nil
+}
+
+// addInstanceTag copies service.instance.id into the instance process tag unless the tag is already set.
+func (c converter) addInstanceTag(process *model.Process) {
+	if c.instanceTag == "" || process == nil {
+		return
+	}
+	tags := model.KeyValues(process.Tags)
+	if _, ok := tags.FindByKey(c.instanceTag); ok {
+		return
+	}
+	if instanceID, ok := tags.FindByKey(serviceInstanceIDAttr); ok {
+		process.Tags = append(process.Tags, model.String(c.instanceTag, instanceID.AsString()))
+	}
+}
+
+// trimProcessTags removes process tags which are not identity keys and returns the removed tags.
+func (c converter) trimProcessTags(process *model.Process) []model.KeyValue {
+	if c.processTagKeys == nil || process == nil {
+		return nil
+	}
+	var kept, trimmed []model.KeyValue
+	for _, tag := range process.Tags {
+		if c.processTagKeys[tag.Key] {
+			kept = append(kept, tag)
+		} else {
+			trimmed = append(trimmed, tag)
+		}
+	}
+	process.Tags = kept
+	return trimmed
+}
+
+// limitProcessTags keeps the maxProcessTags process tags with the lowest keys,
+// so that the same subset is kept regardless of attribute order,
+// and records the number of removed tags in the "dropped_process_tags" tag.
+func (c converter) limitProcessTags(process *model.Process) {
+	if c.maxProcessTags <= 0 || process == nil || len(process.Tags) <= c.maxProcessTags {
+		return
+	}
+	tags := append(model.KeyValues(nil), process.Tags...)
+	sort.Stable(tags)
+	dropped := len(tags) - c.maxProcessTags
+	process.Tags = append(tags[:c.maxProcessTags], model.Int64(droppedProcessTagsTag, int64(dropped)))
+}
+
+// otlpSpans returns the OTLP spans in the order of translated spans,
+// or nil if no conversion option needs them.
+func (c converter) otlpSpans(td pdata.Traces) []pdata.Span {
+	if c.samplingProbabilityKey == "" && !c.tagOrder {
+		return nil
+	}
+	spans := make([]pdata.Span, 0, td.SpanCount())
+	forEachSpan(td, func(span pdata.Span) bool {
+		spans = append(spans, span)
+		return true
+	})
+	return spans
+}
+
+// convertOTLPSpan applies conversion options which need data dropped by the translator.
+func (c converter) convertOTLPSpan(span *model.Span, otlpSpan pdata.Span) {
+	if c.samplingProbabilityKey != "" {
+		if p, ok := samplingProbability(string(otlpSpan.TraceState()), c.samplingProbabilityKey); ok {
+			span.Tags = append(span.Tags, model.Float64(samplerProbabilityTag, p))
+		}
+	}
+	if c.tagOrder {
+		if order := attributeOrder(otlpSpan.Attributes()); order != "" {
+			span.Tags = append(span.Tags, model.String(tagOrderTag, order))
+		}
+	}
+}
+
+// attributeOrder returns comma separated attribute keys in their OTLP order.
+func attributeOrder(attrs pdata.AttributeMap) string {
+	keys := make([]string, 0, attrs.Len())
+	attrs.ForEach(func(k string, _ pdata.AttributeValue) {
+		keys = append(keys, k)
+	})
+	return strings.Join(keys, ",")
+}
+
+// samplingProbability extracts the sampling probability stored under key in W3C trace state.
+// Malformed trace state or values outside of [0, 1] are ignored.
+func samplingProbability(traceState string, key string) (float64, bool) {
+	for _, member := range strings.Split(traceState, ",") {
+		kv := strings.SplitN(strings.TrimSpace(member), "=", 2)
+		if len(kv) != 2 || kv[0] != key {
+			continue
+		}
+		p, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil || p < 0 || p > 1 {
+			return 0, false
+		}
+		return p, true
+	}
+	return 0, false
+}
+
+func (c converter) convertSpan(span *model.Span) {
+	if c.baggageKeys != nil {
+		for i := range span.Tags {
+			if c.baggageKeys[span.Tags[i].Key] {
+				span.Tags[i].Key = baggageTagPrefix + span.Tags[i].Key
+			}
+		}
+	}
+	if c.errorStack {
+		if stack, ok := exceptionStack(span.Logs); ok {
+			span.Tags = append(span.Tags, model.String(errorStackTag, truncate(stack, c.maxTagValueLength)))
+		}
+	}
+	if c.coalesceLogs {
+		span.Logs = coalesceLogs(span.Logs)
+	}
+	if c.normalizeDBStatement {
+		if statement, ok := model.KeyValues(span.Tags).FindByKey(dbStatementAttribute); ok {
+			span.Tags = append(span.Tags, model.String(dbStatementTag, normalizeStatement(statement.AsString())))
+		}
+	}
+	if c.messagingPeerService {
+		if _, ok := model.KeyValues(span.Tags).FindByKey(peerServiceTag); !ok {
+			if peer := messagingPeer(span.Tags); peer != "" {
+				span.Tags = append(span.Tags, model.String(peerServiceTag, peer))
+			}
+		}
+	}
+	if c.rpcTags {
+		addRPCTags(span)
+	}
+	if c.kindMismatch && kindMismatchesReferences(span) {
+		span.Tags = append(span.Tags, model.Bool(kindMismatchTag, true))
+	}
+	if c.sourceLocation {
+		if location := sourceLocation(span.Tags); location != "" {
+			span.Tags = append(span.Tags, model.String(sourceLocationTag, location))
+		}
+	}
+}
+
+// messagingPeer returns the peer of a messaging span formatted as "system/destination",
+// omitting missing components.
+func messagingPeer(tags model.KeyValues) string {
+	var parts []string
+	for _, key := range []string{messagingSystemAttr, messagingDestAttr} {
+		if tag, ok := tags.FindByKey(key); ok {
+			if v := tag.AsString(); v != "" {
+				parts = append(parts, v)
+			}
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// addRPCTags derives "peer.service" of client spans from rpc.service and "rpc.method" from operation
+// names of the form "service/method" used by gRPC. The peer of server spans is the caller, so their
+// peer.service is not derived. Spans without rpc.service are left unchanged, as are existing tags.
+func addRPCTags(span *model.Span) {
+	tags := model.KeyValues(span.Tags)
+	serviceTag, ok := tags.FindByKey(rpcServiceAttr)
+	if !ok || serviceTag.AsString() == "" {
+		return
+	}
+	service := serviceTag.AsString()
+	if _, ok := tags.FindByKey(peerServiceTag); !ok && !span.HasSpanKind(ext.SpanKindRPCServerEnum) {
+		span.Tags = append(span.Tags, model.String(peerServiceTag, service))
+	}
+	if _, ok := tags.FindByKey(rpcMethodAttr); !ok {
+		prefix := service + "/"
+		if strings.HasPrefix(span.OperationName, prefix) && len(span.OperationName) > len(prefix) {
+			span.Tags = append(span.Tags, model.String(rpcMethodAttr, span.OperationName[len(prefix):]))
+		}
+	}
+}
+
+// kindMismatchesReferences returns true for client and producer spans without references,
+// as an outgoing request is expected to be made on behalf of a parent operation.
+func kindMismatchesReferences(span *model.Span) bool {
+	if len(span.References) > 0 {
+		return false
+	}
+	return span.HasSpanKind(ext.SpanKindRPCClientEnum) || span.HasSpanKind(ext.SpanKindProducerEnum)
+}
+
+// exceptionStack returns the stacktrace of the first exception event of the span.
+func exceptionStack(logs []model.Log) (string, bool) {
+	for _, log := range logs {
+		if field, ok := model.KeyValues(log.Fields).FindByKey(exceptionStackField); ok {
+			return field.AsString(), true
+		}
+	}
+	return "", false
+}
+
+// truncate shortens value to at most maxLength bytes without splitting UTF-8 characters.
+// Values are not truncated if maxLength is not positive.
+func truncate(value string, maxLength int) string {
+	if maxLength <= 0 || len(value) <= maxLength {
+		return value
+	}
+	end := maxLength
+	for end > 0 && !utf8.RuneStart(value[end]) {
+		end--
+	}
+	return value[:end]
+}
+
+// lowercaseKeys copies the map with lowercased keys, matching the keys of maps loaded by viper.
+func lowercaseKeys(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	lowercased := make(map[string]string, len(m))
+	for key, value := range m {
+		lowercased[strings.ToLower(key)] = value
+	}
+	return lowercased
+}
+
+// coalesceLogs collapses runs of consecutive logs with equal fields into the first log of the run,
+// recording the length of the run in the "repeat" field.
+func coalesceLogs(logs []model.Log) []model.Log {
+	if len(logs) < 2 {
+		return logs
+	}
+	coalesced := logs[:1]
+	repeat := int64(1)
+	for _, log := range logs[1:] {
+		last := &coalesced[len(coalesced)-1]
+		if model.KeyValues(log.Fields).Equal(last.Fields) {
+			repeat++
+			continue
+		}
+		addRepeatField(last, repeat)
+		coalesced = append(coalesced, log)
+		repeat = 1
+	}
+	addRepeatField(&coalesced[len(coalesced)-1], repeat)
+	return coalesced
+}
+
+func addRepeatField(log *model.Log, repeat int64) {
+	if repeat > 1 {
+		log.Fields = append(log.Fields, model.Int64(logRepeatField, repeat))
+	}
+}
+
+// sourceLocation combines code attributes into "file:line:function", omitting missing components.
+func sourceLocation(tags model.KeyValues) string {
+	var parts []string
+	for _, key := range []string{codeFilepathAttribute, codeLinenoAttribute, codeFunctionAttribute} {
+		if tag, ok := tags.FindByKey(key); ok {
+			if v := tag.AsString(); v != "" {
+				parts = append(parts, v)
+			}
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+// classifyConversionFailure inspects traces rejected by the translator
+// and returns the cause of the failure for the first invalid span.
+func classifyConversionFailure(td pdata.Traces) string {
+	cause := conversionFailureUnknown
+	forEachSpan(td, func(span pdata.Span) bool {
+		if c := spanIDsFailure(span); c != "" {
+			cause = c
+			return false
+		}
+		return true
+	})
+	return cause
+}
+
+func spanIDsFailure(span pdata.Span) string {
+	traceID, spanID := span.TraceID().Bytes(), span.SpanID().Bytes()
+	switch {
+	case traceID == nil:
+		return conversionFailureNilTraceID
+	case len(traceID) != traceIDLength:
+		return conversionFailureInvalidLength
+	case isZero(traceID):
+		return conversionFailureZeroTraceID
+	case spanID == nil:
+		return conversionFailureNilSpanID
+	case len(spanID) != spanIDLength:
+		return conversionFailureInvalidLength
+	case isZero(spanID):
+		return conversionFailureZeroSpanID
+	}
+	if parentID := span.ParentSpanID().Bytes(); len(parentID) != 0 && (len(parentID) != spanIDLength || isZero(parentID)) {
+		return conversionFailureInvalidParentID
+	}
+	return ""
+}
+
+func isZero(id []byte) bool {
+	for _, b := range id {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// addComponentTag adds the legacy "component" tag with the instrumentation library name,
+// unless the span already has the tag or the library has no name.
+func addComponentTag(span *model.Span, libraryName string) {
+	if libraryName == "" {
+		return
+	}
+	if _, ok := model.KeyValues(span.Tags).FindByKey(string(ext.Component)); !ok {
+		span.Tags = append(span.Tags, model.String(string(ext.Component), libraryName))
+	}
+}
+
+// spanKinds returns kinds of OTLP spans in the order of translated spans.
+// The translator does not keep the INTERNAL kind.
+func spanKinds(td pdata.Traces) []pdata.SpanKind {
+	kinds := make([]pdata.SpanKind, 0, td.SpanCount())
+	forEachSpan(td, func(span pdata.Span) bool {
+		kinds = append(kinds, span.Kind())
+		return true
+	})
+	return kinds
+}
+
+// unfinishedSpans reports OTLP spans without an end time, i.e. spans sent before they finished,
+// in the order of translated spans. The translated duration does not distinguish them from
+// spans which finished when they started.
+func unfinishedSpans(td pdata.Traces) []bool {
+	unfinished := make([]bool, 0, td.SpanCount())
+	forEachSpan(td, func(span pdata.Span) bool {
+		unfinished = append(unfinished, span.EndTime() == 0)
+		return true
+	})
+	return unfinished
+}
+
+// instrumentationLibraryNames returns names of instrumentation libraries of OTLP spans
+// in the order of translated spans. The translator does not keep them.
+func instrumentationLibraryNames(td pdata.Traces) []string {
+	names := make([]string, 0, td.SpanCount())
+	forEachLibrarySpan(td, func(ils pdata.InstrumentationLibrarySpans, _ pdata.Span) bool {
+		name := ""
+		if library := ils.InstrumentationLibrary(); !library.IsNil() {
+			name = library.Name()
+		}
+		names = append(names, name)
+		return true
+	})
+	return names
+}
+
+// forEachSpan calls fn for each non-nil span in the order used by the translator.
+// Iteration stops when fn returns false.
+func forEachSpan(td pdata.Traces, fn func(span pdata.Span) bool) {
+	forEachLibrarySpan(td, func(_ pdata.InstrumentationLibrarySpans, span pdata.Span) bool {
+		return fn(span)
+	})
+}
+
+// forEachLibrarySpan is like forEachSpan, also passing the instrumentation library spans containing the span.
+func forEachLibrarySpan(td pdata.Traces, fn func(ils pdata.InstrumentationLibrarySpans, span pdata.Span) bool) {
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		if rs.IsNil() {
+			continue
+		}
+		ilss := rs.InstrumentationLibrarySpans()
+		for j := 0; j < ilss.Len(); j++ {
+			ils := ilss.At(j)
+			if ils.IsNil() {
+				continue
+			}
+			spans := ils.Spans()
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				if span.IsNil() {
+					continue
+				}
+				if !fn(ils, span) {
+					return
+				}
+			}
+		}
+	}
+}
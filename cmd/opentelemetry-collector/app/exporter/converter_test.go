@@ -0,0 +1,767 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	commonv1 "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	resourcev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics"
+	"github.com/uber/jaeger-lib/metrics/metricstest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+var (
+	testTraceID = []byte("0123456789abcdef")
+	testSpanID  = []byte("01234567")
+)
+
+func stringAttr(key, value string) *commonv1.AttributeKeyValue {
+	return &commonv1.AttributeKeyValue{Key: key, Type: commonv1.AttributeKeyValue_STRING, StringValue: value}
+}
+
+func tracesWithSpans(spans ...*tracev1.Span) pdata.Traces {
+	return pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+		InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: spans}},
+	}})
+}
+
+func convertSingleSpan(t *testing.T, c converter, td pdata.Traces) *model.Span {
+	batches, err := c.convert(td)
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0].Spans, 1)
+	return batches[0].Spans[0]
+}
+
+func TestConvert_baggageKeys(t *testing.T) {
+	c := newConverter(Options{BaggageKeys: []string{"user.id"}})
+	span := convertSingleSpan(t, c, tracesWithSpans(&tracev1.Span{
+		TraceId:    testTraceID,
+		SpanId:     testSpanID,
+		Attributes: []*commonv1.AttributeKeyValue{stringAttr("user.id", "42"), stringAttr("http.method", "GET")},
+	}))
+	assert.Equal(t, []model.KeyValue{
+		model.String("baggage.user.id", "42"),
+		model.String("http.method", "GET"),
+	}, span.Tags)
+}
+
+func TestConvert_noBaggageKeys(t *testing.T) {
+	c := newConverter(Options{})
+	span := convertSingleSpan(t, c, tracesWithSpans(&tracev1.Span{
+		TraceId:    testTraceID,
+		SpanId:     testSpanID,
+		Attributes: []*commonv1.AttributeKeyValue{stringAttr("user.id", "42")},
+	}))
+	assert.Equal(t, []model.KeyValue{model.String("user.id", "42")}, span.Tags)
+}
+
+func TestStore_conversionFailureCause(t *testing.T) {
+	tests := []struct {
+		caption string
+		span    *tracev1.Span
+		cause   string
+	}{
+		{caption: "nil trace ID", span: &tracev1.Span{SpanId: testSpanID}, cause: "nil_trace_id"},
+		{caption: "short trace ID", span: &tracev1.Span{TraceId: []byte("0123"), SpanId: testSpanID}, cause: "invalid_length"},
+		{caption: "zero trace ID", span: &tracev1.Span{TraceId: make([]byte, 16), SpanId: testSpanID}, cause: "zero_trace_id"},
+		{caption: "nil span ID", span: &tracev1.Span{TraceId: testTraceID}, cause: "nil_span_id"},
+		{caption: "long span ID", span: &tracev1.Span{TraceId: testTraceID, SpanId: []byte("0123456789")}, cause: "invalid_length"},
+		{caption: "zero span ID", span: &tracev1.Span{TraceId: testTraceID, SpanId: make([]byte, 8)}, cause: "zero_span_id"},
+		{caption: "zero parent span ID", span: &tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, ParentSpanId: make([]byte, 8)}, cause: "invalid_parent_span_id"},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			metricsFactory := metricstest.NewFactory(0)
+			s := newStorage(spanWriter{}, Options{MetricsFactory: metricsFactory})
+			td := tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}, test.span)
+			dropped, err := s.traceDataPusher(context.Background(), td)
+			require.Error(t, err)
+			assert.Equal(t, 2, dropped)
+			counters, _ := metricsFactory.Snapshot()
+			assert.Equal(t, map[string]int64{
+				metrics.GetKey("jaeger_exporter.spans.conversion_failed", map[string]string{"cause": test.cause}, "|", "="): 2,
+			}, nonZero(counters))
+		})
+	}
+}
+
+func nonZero(counters map[string]int64) map[string]int64 {
+	m := make(map[string]int64)
+	for k, v := range counters {
+		if v != 0 {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+func TestConvert_samplingProbability(t *testing.T) {
+	tests := []struct {
+		caption    string
+		traceState string
+		tags       []model.KeyValue
+	}{
+		{caption: "valid", traceState: "vendor=abc, p=0.25", tags: []model.KeyValue{model.Float64("sampler.probability", 0.25)}},
+		{caption: "missing key", traceState: "vendor=abc"},
+		{caption: "no value", traceState: "p"},
+		{caption: "not a number", traceState: "p=abc"},
+		{caption: "out of range", traceState: "p=1.5"},
+		{caption: "empty", traceState: ""},
+	}
+	c := newConverter(Options{SamplingProbabilityTraceStateKey: "p"})
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			span := convertSingleSpan(t, c, tracesWithSpans(&tracev1.Span{
+				TraceId:    testTraceID,
+				SpanId:     testSpanID,
+				TraceState: test.traceState,
+			}))
+			assert.Equal(t, test.tags, []model.KeyValue(span.Tags))
+		})
+	}
+}
+
+func TestConvert_samplingProbabilityAcrossResources(t *testing.T) {
+	c := newConverter(Options{SamplingProbabilityTraceStateKey: "p"})
+	batches, err := c.convert(pdata.TracesFromOtlp([]*tracev1.ResourceSpans{
+		{InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{
+			{Spans: []*tracev1.Span{{TraceId: testTraceID, SpanId: testSpanID, TraceState: "p=0.5"}}},
+		}},
+		nil,
+		{InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{
+			{Spans: []*tracev1.Span{nil, {TraceId: testTraceID, SpanId: testSpanID, TraceState: "p=0.1"}}},
+		}},
+	}))
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+	assert.Equal(t, []model.KeyValue{model.Float64("sampler.probability", 0.5)}, batches[0].Spans[0].Tags)
+	assert.Equal(t, []model.KeyValue{model.Float64("sampler.probability", 0.1)}, batches[1].Spans[0].Tags)
+}
+
+func TestConvert_sourceLocation(t *testing.T) {
+	lineno := &commonv1.AttributeKeyValue{Key: "code.lineno", Type: commonv1.AttributeKeyValue_INT, IntValue: 42}
+	tests := []struct {
+		caption  string
+		attrs    []*commonv1.AttributeKeyValue
+		location string
+	}{
+		{
+			caption:  "all code attributes",
+			attrs:    []*commonv1.AttributeKeyValue{stringAttr("code.function", "main"), stringAttr("code.filepath", "main.go"), lineno},
+			location: "main.go:42:main",
+		},
+		{
+			caption:  "missing line",
+			attrs:    []*commonv1.AttributeKeyValue{stringAttr("code.filepath", "main.go"), stringAttr("code.function", "main")},
+			location: "main.go:main",
+		},
+		{
+			caption:  "function only",
+			attrs:    []*commonv1.AttributeKeyValue{stringAttr("code.function", "main")},
+			location: "main",
+		},
+		{
+			caption: "no code attributes",
+			attrs:   []*commonv1.AttributeKeyValue{stringAttr("http.method", "GET")},
+		},
+	}
+	c := newConverter(Options{SourceLocationTag: true})
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			span := convertSingleSpan(t, c, tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Attributes: test.attrs}))
+			tag, ok := model.KeyValues(span.Tags).FindByKey("source.location")
+			if test.location == "" {
+				assert.False(t, ok)
+				assert.Len(t, span.Tags, len(test.attrs))
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, test.location, tag.VStr)
+			assert.Len(t, span.Tags, len(test.attrs)+1, "original attributes are kept")
+		})
+	}
+}
+
+func TestConvert_processTagKeys(t *testing.T) {
+	td := pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+		Resource: &resourcev1.Resource{Attributes: []*commonv1.AttributeKeyValue{
+			stringAttr("service.name", "frontend"),
+			stringAttr("host.name", "node-1"),
+			stringAttr("k8s.pod.uid", "1234"),
+			stringAttr("os.type", "linux"),
+		}},
+		InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: []*tracev1.Span{
+			{TraceId: testTraceID, SpanId: testSpanID, Attributes: []*commonv1.AttributeKeyValue{stringAttr("http.method", "GET")}},
+		}}},
+	}})
+	tests := []struct {
+		caption      string
+		options      Options
+		processTags  []model.KeyValue
+		spanTagCount int
+	}{
+		{
+			caption:      "all tags kept",
+			options:      Options{},
+			processTags:  []model.KeyValue{model.String("host.name", "node-1"), model.String("k8s.pod.uid", "1234"), model.String("os.type", "linux")},
+			spanTagCount: 1,
+		},
+		{
+			caption:      "trimmed",
+			options:      Options{ProcessTagKeys: []string{"host.name"}},
+			processTags:  []model.KeyValue{model.String("host.name", "node-1")},
+			spanTagCount: 1,
+		},
+		{
+			caption:      "trimmed and moved to span",
+			options:      Options{ProcessTagKeys: []string{"host.name"}, MoveTrimmedProcessTagsToSpans: true},
+			processTags:  []model.KeyValue{model.String("host.name", "node-1")},
+			spanTagCount: 3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			batches, err := newConverter(test.options).convert(td)
+			require.NoError(t, err)
+			require.Len(t, batches, 1)
+			assert.Equal(t, "frontend", batches[0].Process.ServiceName)
+			assert.ElementsMatch(t, test.processTags, batches[0].Process.Tags)
+			span := batches[0].Spans[0]
+			assert.Len(t, span.Tags, test.spanTagCount)
+			if test.options.MoveTrimmedProcessTagsToSpans {
+				assert.Contains(t, span.Tags, model.String("k8s.pod.uid", "1234"))
+				assert.Contains(t, span.Tags, model.String("os.type", "linux"))
+			}
+		})
+	}
+}
+
+func TestConvert_tagOrder(t *testing.T) {
+	c := newConverter(Options{TagOrderTag: true})
+	attrs := []*commonv1.AttributeKeyValue{stringAttr("z", "1"), stringAttr("a", "2"), stringAttr("m", "3")}
+	span := convertSingleSpan(t, c, tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Attributes: attrs}))
+	tag, ok := model.KeyValues(span.Tags).FindByKey("tag.order")
+	require.True(t, ok)
+	assert.Equal(t, "z,a,m", tag.VStr)
+
+	// reconstruct the original order from tags sorted by the storage
+	model.KeyValues(span.Tags).Sort()
+	var restored []string
+	for _, key := range strings.Split(tag.VStr, ",") {
+		kv, ok := model.KeyValues(span.Tags).FindByKey(key)
+		require.True(t, ok)
+		restored = append(restored, kv.Key+"="+kv.VStr)
+	}
+	assert.Equal(t, []string{"z=1", "a=2", "m=3"}, restored)
+
+	span = convertSingleSpan(t, c, tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}))
+	assert.Empty(t, span.Tags, "no tag for spans without attributes")
+}
+
+func TestConvert_instanceTag(t *testing.T) {
+	tests := []struct {
+		caption     string
+		attrs       []*commonv1.AttributeKeyValue
+		processTags []model.KeyValue
+	}{
+		{
+			caption:     "instance id",
+			attrs:       []*commonv1.AttributeKeyValue{stringAttr("service.instance.id", "pod-1")},
+			processTags: []model.KeyValue{model.String("service.instance.id", "pod-1"), model.String("hostname", "pod-1")},
+		},
+		{
+			caption:     "existing tag kept",
+			attrs:       []*commonv1.AttributeKeyValue{stringAttr("hostname", "node-1"), stringAttr("service.instance.id", "pod-1")},
+			processTags: []model.KeyValue{model.String("hostname", "node-1"), model.String("service.instance.id", "pod-1")},
+		},
+		{
+			caption:     "missing instance id",
+			attrs:       []*commonv1.AttributeKeyValue{stringAttr("os.type", "linux")},
+			processTags: []model.KeyValue{model.String("os.type", "linux")},
+		},
+	}
+	c := newConverter(Options{InstanceTag: "hostname"})
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			batches, err := c.convert(pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+				Resource: &resourcev1.Resource{Attributes: append(test.attrs, stringAttr("service.name", "frontend"))},
+				InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: []*tracev1.Span{
+					{TraceId: testTraceID, SpanId: testSpanID},
+				}}},
+			}}))
+			require.NoError(t, err)
+			require.Len(t, batches, 1)
+			assert.ElementsMatch(t, test.processTags, batches[0].Process.Tags)
+		})
+	}
+}
+
+func TestConvert_coalesceRepeatedLogs(t *testing.T) {
+	event := func(ts uint64, msg string) *tracev1.Span_Event {
+		return &tracev1.Span_Event{TimeUnixNano: ts, Attributes: []*commonv1.AttributeKeyValue{stringAttr("message", msg)}}
+	}
+	log := func(ts uint64, msg string, repeat int64) model.Log {
+		l := model.Log{Timestamp: time.Unix(0, int64(ts)).UTC(), Fields: []model.KeyValue{model.String("message", msg)}}
+		if repeat > 1 {
+			l.Fields = append(l.Fields, model.Int64("repeat", repeat))
+		}
+		return l
+	}
+	tests := []struct {
+		caption string
+		events  []*tracev1.Span_Event
+		logs    []model.Log
+	}{
+		{
+			caption: "run of identical logs",
+			events:  []*tracev1.Span_Event{event(1, "retry"), event(2, "retry"), event(3, "retry")},
+			logs:    []model.Log{log(1, "retry", 3)},
+		},
+		{
+			caption: "interleaved distinct logs",
+			events:  []*tracev1.Span_Event{event(1, "a"), event(2, "b"), event(3, "a"), event(4, "a"), event(5, "b")},
+			logs:    []model.Log{log(1, "a", 1), log(2, "b", 1), log(3, "a", 2), log(5, "b", 1)},
+		},
+		{
+			caption: "single log",
+			events:  []*tracev1.Span_Event{event(1, "a")},
+			logs:    []model.Log{log(1, "a", 1)},
+		},
+	}
+	c := newConverter(Options{CoalesceRepeatedLogs: true})
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			span := convertSingleSpan(t, c, tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Events: test.events}))
+			assert.Equal(t, test.logs, span.Logs)
+		})
+	}
+}
+
+func TestConvert_kindMismatchTag(t *testing.T) {
+	tests := []struct {
+		caption  string
+		kind     tracev1.Span_SpanKind
+		parent   []byte
+		links    []*tracev1.Span_Link
+		mismatch bool
+	}{
+		{caption: "client with parent", kind: tracev1.Span_CLIENT, parent: []byte("76543210")},
+		{caption: "client with link", kind: tracev1.Span_CLIENT, links: []*tracev1.Span_Link{{TraceId: testTraceID, SpanId: []byte("76543210")}}},
+		{caption: "root client", kind: tracev1.Span_CLIENT, mismatch: true},
+		{caption: "root producer", kind: tracev1.Span_PRODUCER, mismatch: true},
+		{caption: "root server", kind: tracev1.Span_SERVER},
+		{caption: "root consumer", kind: tracev1.Span_CONSUMER},
+		{caption: "root internal", kind: tracev1.Span_INTERNAL},
+	}
+	c := newConverter(Options{KindMismatchTag: true})
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			span := convertSingleSpan(t, c, tracesWithSpans(&tracev1.Span{
+				TraceId:      testTraceID,
+				SpanId:       testSpanID,
+				ParentSpanId: test.parent,
+				Kind:         test.kind,
+				Links:        test.links,
+			}))
+			tag, ok := model.KeyValues(span.Tags).FindByKey("jaeger.kind_mismatch")
+			assert.Equal(t, test.mismatch, ok)
+			if test.mismatch {
+				assert.True(t, tag.Bool())
+			}
+		})
+	}
+}
+
+func TestConvert_messagingPeerService(t *testing.T) {
+	tests := []struct {
+		caption string
+		attrs   []*commonv1.AttributeKeyValue
+		peer    string
+	}{
+		{
+			caption: "messaging span",
+			attrs:   []*commonv1.AttributeKeyValue{stringAttr("messaging.system", "kafka"), stringAttr("messaging.destination", "orders")},
+			peer:    "kafka/orders",
+		},
+		{
+			caption: "missing destination",
+			attrs:   []*commonv1.AttributeKeyValue{stringAttr("messaging.system", "rabbitmq")},
+			peer:    "rabbitmq",
+		},
+		{
+			caption: "existing peer.service",
+			attrs:   []*commonv1.AttributeKeyValue{stringAttr("peer.service", "broker"), stringAttr("messaging.system", "kafka")},
+			peer:    "broker",
+		},
+		{
+			caption: "non-messaging span",
+			attrs:   []*commonv1.AttributeKeyValue{stringAttr("http.method", "GET")},
+		},
+	}
+	c := newConverter(Options{MessagingPeerService: true})
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			span := convertSingleSpan(t, c, tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Attributes: test.attrs}))
+			tag, ok := model.KeyValues(span.Tags).FindByKey("peer.service")
+			assert.Equal(t, test.peer != "", ok)
+			assert.Equal(t, test.peer, tag.VStr)
+			if test.peer == "" {
+				assert.Len(t, span.Tags, len(test.attrs))
+			}
+		})
+	}
+}
+
+func TestConvert_sanitizeNames(t *testing.T) {
+	tests := []struct {
+		caption       string
+		service       string
+		operation     string
+		sanitized     []string
+		wantService   string
+		wantOperation string
+	}{
+		{caption: "valid names", service: "frontend", operation: "GET /", wantService: "frontend", wantOperation: "GET /"},
+		{caption: "invalid operation", service: "frontend", operation: "GET /\xff", sanitized: []string{"operation"}, wantService: "frontend", wantOperation: "GET /\uFFFD"},
+		{caption: "invalid service", service: "front\xc3end", operation: "GET /", sanitized: []string{"service"}, wantService: "front\uFFFDend", wantOperation: "GET /"},
+		{caption: "invalid run of bytes", service: "\xfe\xffsvc", operation: "op\xe2\x82", sanitized: []string{"service", "operation"}, wantService: "\uFFFDsvc", wantOperation: "op\uFFFD"},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			metricsFactory := metricstest.NewFactory(0)
+			c := newConverter(Options{SanitizeNames: true, MetricsFactory: metricsFactory})
+			batches, err := c.convert(pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+				Resource: &resourcev1.Resource{Attributes: []*commonv1.AttributeKeyValue{stringAttr("service.name", test.service)}},
+				InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{
+					Spans: []*tracev1.Span{{TraceId: testTraceID, SpanId: testSpanID, Name: test.operation}},
+				}},
+			}}))
+			require.NoError(t, err)
+			require.Len(t, batches, 1)
+			assert.Equal(t, test.wantService, batches[0].Process.ServiceName)
+			assert.Equal(t, test.wantOperation, batches[0].Spans[0].OperationName)
+			counts := map[string]int64{"operation": 0, "service": 0}
+			for _, name := range test.sanitized {
+				counts[name]++
+			}
+			for name, count := range counts {
+				metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{
+					Name:  "jaeger_exporter.names.sanitized",
+					Tags:  map[string]string{"name": name},
+					Value: int(count),
+				})
+			}
+		})
+	}
+}
+
+func TestConvert_rpcTags(t *testing.T) {
+	tests := []struct {
+		caption string
+		name    string
+		kind    tracev1.Span_SpanKind
+		attrs   []*commonv1.AttributeKeyValue
+		peer    string
+		method  string
+	}{
+		{
+			caption: "rpc span",
+			name:    "helloworld.Greeter/SayHello",
+			kind:    tracev1.Span_CLIENT,
+			attrs:   []*commonv1.AttributeKeyValue{stringAttr("rpc.system", "grpc"), stringAttr("rpc.service", "helloworld.Greeter")},
+			peer:    "helloworld.Greeter",
+			method:  "SayHello",
+		},
+		{
+			caption: "server span",
+			name:    "helloworld.Greeter/SayHello",
+			kind:    tracev1.Span_SERVER,
+			attrs:   []*commonv1.AttributeKeyValue{stringAttr("rpc.service", "helloworld.Greeter")},
+			method:  "SayHello",
+		},
+		{
+			caption: "existing tags",
+			name:    "helloworld.Greeter/SayHello",
+			attrs: []*commonv1.AttributeKeyValue{
+				stringAttr("rpc.service", "helloworld.Greeter"),
+				stringAttr("rpc.method", "Hello"),
+				stringAttr("peer.service", "greeter"),
+			},
+			peer:   "greeter",
+			method: "Hello",
+		},
+		{
+			caption: "operation name without method",
+			name:    "SayHello",
+			attrs:   []*commonv1.AttributeKeyValue{stringAttr("rpc.service", "helloworld.Greeter")},
+			peer:    "helloworld.Greeter",
+		},
+		{
+			caption: "missing rpc.service",
+			name:    "helloworld.Greeter/SayHello",
+			attrs:   []*commonv1.AttributeKeyValue{stringAttr("rpc.system", "grpc")},
+		},
+	}
+	c := newConverter(Options{RPCTags: true})
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			span := convertSingleSpan(t, c, tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Name: test.name, Kind: test.kind, Attributes: test.attrs}))
+			tags := model.KeyValues(span.Tags)
+			peer, ok := tags.FindByKey("peer.service")
+			assert.Equal(t, test.peer != "", ok)
+			assert.Equal(t, test.peer, peer.VStr)
+			method, ok := tags.FindByKey("rpc.method")
+			assert.Equal(t, test.method != "", ok)
+			assert.Equal(t, test.method, method.VStr)
+		})
+	}
+}
+
+func TestConvert_componentTagFromLibrary(t *testing.T) {
+	tests := []struct {
+		caption   string
+		library   *commonv1.InstrumentationLibrary
+		attrs     []*commonv1.AttributeKeyValue
+		component string
+		tags      int
+	}{
+		{
+			caption:   "derived from library name",
+			library:   &commonv1.InstrumentationLibrary{Name: "net/http", Version: "1.0"},
+			component: "net/http",
+			tags:      1,
+		},
+		{
+			caption:   "existing component",
+			library:   &commonv1.InstrumentationLibrary{Name: "net/http"},
+			attrs:     []*commonv1.AttributeKeyValue{stringAttr("component", "grpc")},
+			component: "grpc",
+			tags:      1,
+		},
+		{
+			caption: "library without name",
+			library: &commonv1.InstrumentationLibrary{Version: "1.0"},
+		},
+		{
+			caption: "no library",
+		},
+	}
+	c := newConverter(Options{ComponentTagFromLibrary: true})
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			td := pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+				InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{
+					InstrumentationLibrary: test.library,
+					Spans:                  []*tracev1.Span{{TraceId: testTraceID, SpanId: testSpanID, Attributes: test.attrs}},
+				}},
+			}})
+			span := convertSingleSpan(t, c, td)
+			tag, ok := model.KeyValues(span.Tags).FindByKey("component")
+			assert.Equal(t, test.component != "", ok)
+			assert.Equal(t, test.component, tag.VStr)
+			assert.Len(t, span.Tags, test.tags)
+		})
+	}
+}
+
+func TestConvert_maxProcessTags(t *testing.T) {
+	resource := func(attrs ...*commonv1.AttributeKeyValue) pdata.Traces {
+		return pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+			Resource: &resourcev1.Resource{Attributes: append(attrs, stringAttr("service.name", "frontend"))},
+			InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: []*tracev1.Span{
+				{TraceId: testTraceID, SpanId: testSpanID},
+			}}},
+		}})
+	}
+	c := newConverter(Options{MaxProcessTags: 2})
+	tests := []struct {
+		caption     string
+		td          pdata.Traces
+		processTags []model.KeyValue
+	}{
+		{
+			caption: "trimmed",
+			td:      resource(stringAttr("os.type", "linux"), stringAttr("host.name", "node-1"), stringAttr("k8s.pod.uid", "1234")),
+			processTags: []model.KeyValue{
+				model.String("host.name", "node-1"),
+				model.String("k8s.pod.uid", "1234"),
+				model.Int64("dropped_process_tags", 1),
+			},
+		},
+		{
+			caption: "same subset regardless of order",
+			td:      resource(stringAttr("k8s.pod.uid", "1234"), stringAttr("os.type", "linux"), stringAttr("host.name", "node-1")),
+			processTags: []model.KeyValue{
+				model.String("host.name", "node-1"),
+				model.String("k8s.pod.uid", "1234"),
+				model.Int64("dropped_process_tags", 1),
+			},
+		},
+		{
+			caption:     "within limit",
+			td:          resource(stringAttr("os.type", "linux"), stringAttr("host.name", "node-1")),
+			processTags: []model.KeyValue{model.String("os.type", "linux"), model.String("host.name", "node-1")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			batches, err := c.convert(test.td)
+			require.NoError(t, err)
+			require.Len(t, batches, 1)
+			assert.Equal(t, test.processTags, batches[0].Process.Tags)
+		})
+	}
+}
+
+func TestConvert_peerServicePassthrough(t *testing.T) {
+	peerService := stringAttr("peer.service", "billing")
+	messaging := stringAttr("messaging.system", "kafka")
+	tests := []struct {
+		caption string
+		options Options
+		attrs   []*commonv1.AttributeKeyValue
+		peer    []model.KeyValue
+	}{
+		{
+			caption: "present",
+			attrs:   []*commonv1.AttributeKeyValue{peerService},
+			peer:    []model.KeyValue{model.String("peer.service", "billing")},
+		},
+		{
+			caption: "present with derivation",
+			options: Options{MessagingPeerService: true},
+			attrs:   []*commonv1.AttributeKeyValue{messaging, peerService},
+			peer:    []model.KeyValue{model.String("peer.service", "billing")},
+		},
+		{
+			caption: "absent",
+			attrs:   []*commonv1.AttributeKeyValue{messaging},
+		},
+		{
+			caption: "absent with derivation",
+			options: Options{MessagingPeerService: true},
+			attrs:   []*commonv1.AttributeKeyValue{messaging},
+			peer:    []model.KeyValue{model.String("peer.service", "kafka")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			span := convertSingleSpan(t, newConverter(test.options), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Attributes: test.attrs}))
+			var peer []model.KeyValue
+			for _, tag := range span.Tags {
+				if tag.Key == "peer.service" {
+					peer = append(peer, tag)
+				}
+			}
+			assert.Equal(t, test.peer, peer)
+		})
+	}
+}
+
+func TestConvert_serviceNameMap(t *testing.T) {
+	c := newConverter(Options{ServiceNameMap: map[string]string{"legacy-frontend": "frontend"}})
+	for _, test := range []struct {
+		service string
+		stored  string
+	}{
+		{service: "legacy-frontend", stored: "frontend"},
+		{service: "backend", stored: "backend"},
+	} {
+		batches, err := c.convert(pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+			Resource: &resourcev1.Resource{Attributes: []*commonv1.AttributeKeyValue{stringAttr("service.name", test.service)}},
+			InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: []*tracev1.Span{
+				{TraceId: testTraceID, SpanId: testSpanID},
+			}}},
+		}}))
+		require.NoError(t, err)
+		require.Len(t, batches, 1)
+		assert.Equal(t, test.stored, batches[0].Process.ServiceName, test.service)
+	}
+}
+
+func TestConvert_serviceNameMapFromConfig(t *testing.T) {
+	c := newConverter(loadOptions(t))
+	for _, service := range []string{"Legacy-FrontEnd", "legacy-frontend"} {
+		batches, err := c.convert(pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+			Resource: &resourcev1.Resource{Attributes: []*commonv1.AttributeKeyValue{stringAttr("service.name", service)}},
+			InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: []*tracev1.Span{
+				{TraceId: testTraceID, SpanId: testSpanID},
+			}}},
+		}}))
+		require.NoError(t, err)
+		require.Len(t, batches, 1)
+		assert.Equal(t, "FrontEnd", batches[0].Process.ServiceName, service)
+	}
+}
+
+// loadOptions reads options from testdata the way the collector reads exporter configuration.
+func loadOptions(t *testing.T) Options {
+	v := viper.New()
+	v.SetConfigFile(path.Join(".", "testdata", "options.yaml"))
+	require.NoError(t, v.ReadInConfig())
+	var opts Options
+	require.NoError(t, v.UnmarshalExact(&opts))
+	return opts
+}
+
+func TestConvert_errorStackTag(t *testing.T) {
+	stack := "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:12"
+	events := []*tracev1.Span_Event{
+		{Name: "retry", Attributes: []*commonv1.AttributeKeyValue{stringAttr("attempt", "1")}},
+		{Name: "exception", Attributes: []*commonv1.AttributeKeyValue{
+			stringAttr("exception.type", "panic"),
+			stringAttr("exception.stacktrace", stack),
+		}},
+	}
+	tests := []struct {
+		caption   string
+		maxLength int
+		stack     string
+	}{
+		{caption: "full stack", stack: stack},
+		{caption: "truncated stack", maxLength: 11, stack: "panic: boom"},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			c := newConverter(Options{ErrorStackTag: true, MaxTagValueLength: test.maxLength})
+			span := convertSingleSpan(t, c, tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Events: events}))
+			assert.Equal(t, []model.KeyValue{model.String("error.stack", test.stack)}, span.Tags)
+			assert.Len(t, span.Logs, 2, "events are kept")
+		})
+	}
+
+	span := convertSingleSpan(t, newConverter(Options{ErrorStackTag: true}), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Events: events[:1]}))
+	assert.Empty(t, span.Tags)
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "abc", truncate("abc", 0))
+	assert.Equal(t, "abc", truncate("abc", 3))
+	assert.Equal(t, "ab", truncate("abc", 2))
+	assert.Equal(t, "a", truncate("aé", 2), "multi-byte characters are not split")
+}
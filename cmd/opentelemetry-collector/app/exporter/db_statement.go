@@ -0,0 +1,42 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	dbStatementAttribute = "db.statement"
+	dbStatementTag       = "db.statement.normalized"
+)
+
+var (
+	stringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numericLiteral = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	whitespace     = regexp.MustCompile(`\s+`)
+	placeholders   = regexp.MustCompile(`\( ?\?(?: ?, ?\?)* ?\)`)
+)
+
+// normalizeStatement replaces string and numeric literals of a database statement with "?",
+// collapses lists of literals and whitespace, so that executions of a query share the same value.
+func normalizeStatement(statement string) string {
+	normalized := stringLiteral.ReplaceAllString(statement, "?")
+	normalized = numericLiteral.ReplaceAllString(normalized, "?")
+	normalized = whitespace.ReplaceAllString(normalized, " ")
+	normalized = placeholders.ReplaceAllString(normalized, "(?)")
+	return strings.TrimSpace(normalized)
+}
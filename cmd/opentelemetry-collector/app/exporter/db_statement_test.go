@@ -0,0 +1,67 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+
+	commonv1 "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+func TestNormalizeStatement(t *testing.T) {
+	tests := []struct {
+		statement  string
+		normalized string
+	}{
+		{
+			statement:  "SELECT * FROM users WHERE id = 42 AND name = 'O''Brien'",
+			normalized: "SELECT * FROM users WHERE id = ? AND name = ?",
+		},
+		{
+			statement:  "SELECT *\n\tFROM  orders\n WHERE total > 10.5 AND status IN ( 'new', 'paid' , 'sent' )",
+			normalized: "SELECT * FROM orders WHERE total > ? AND status IN (?)",
+		},
+		{
+			statement:  "INSERT INTO table1 (a, b) VALUES (1, 'x')",
+			normalized: "INSERT INTO table1 (a, b) VALUES (?)",
+		},
+		{
+			statement:  "UPDATE t SET v = $1 WHERE id = ?",
+			normalized: "UPDATE t SET v = $? WHERE id = ?",
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.normalized, normalizeStatement(test.statement), test.statement)
+	}
+}
+
+func TestConvert_normalizeDBStatement(t *testing.T) {
+	statement := "SELECT name FROM users WHERE id = 7"
+	attrs := []*commonv1.AttributeKeyValue{stringAttr("db.statement", statement)}
+	span := convertSingleSpan(t, newConverter(Options{NormalizeDBStatement: true}), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Attributes: attrs}))
+	assert.Equal(t, []model.KeyValue{
+		model.String("db.statement", statement),
+		model.String("db.statement.normalized", "SELECT name FROM users WHERE id = ?"),
+	}, span.Tags)
+
+	span = convertSingleSpan(t, newConverter(Options{}), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Attributes: attrs}))
+	_, ok := model.KeyValues(span.Tags).FindByKey("db.statement.normalized")
+	require.False(t, ok)
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/jaegertracing/jaeger/model"
+)
+
+const (
+	depthTag     = "jaeger.depth"
+	unknownDepth = -1
+)
+
+// addDepthTags tags spans with their depth in the trace tree, roots having depth zero.
+// Only spans of the batches are considered, spans with an ancestor missing from the batches
+// have unknown depth.
+func addDepthTags(batches []*model.Batch) {
+	spans := make(map[spanKey]*model.Span)
+	for _, batch := range batches {
+		for _, span := range batch.Spans {
+			spans[spanKey{traceID: span.TraceID, spanID: span.SpanID}] = span
+		}
+	}
+	depths := make(map[spanKey]int64, len(spans))
+	for _, batch := range batches {
+		for _, span := range batch.Spans {
+			span.Tags = append(span.Tags, model.Int64(depthTag, spanDepth(span, spans, depths)))
+		}
+	}
+}
+
+// spanDepth walks up the references of the span until a root, a span of known depth
+// or a missing parent is found, memoizing depths of visited spans.
+func spanDepth(span *model.Span, spans map[spanKey]*model.Span, depths map[spanKey]int64) int64 {
+	var path []spanKey
+	// depth of the last span in path
+	depth := int64(unknownDepth)
+	for {
+		key := spanKey{traceID: span.TraceID, spanID: span.SpanID}
+		if d, ok := depths[key]; ok {
+			if len(path) == 0 {
+				return d
+			}
+			if d != unknownDepth {
+				depth = d + 1
+			}
+			break
+		}
+		path = append(path, key)
+		parentID := span.ParentSpanID()
+		if parentID == 0 {
+			depth = 0
+			break
+		}
+		parent, ok := spans[spanKey{traceID: span.TraceID, spanID: parentID}]
+		if !ok || len(path) > len(spans) {
+			// missing parent or a reference cycle
+			break
+		}
+		span = parent
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		depths[path[i]] = depth
+		if depth != unknownDepth {
+			depth++
+		}
+	}
+	return depths[path[0]]
+}
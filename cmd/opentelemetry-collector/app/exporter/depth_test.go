@@ -0,0 +1,81 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+func TestAddDepthTags(t *testing.T) {
+	traceID := model.NewTraceID(1, 1)
+	root := childSpan(traceID, 1, 0)
+	child := childSpan(traceID, 2, 1)
+	grandChild := childSpan(traceID, 3, 2)
+	sibling := childSpan(traceID, 4, 1)
+	orphan := childSpan(traceID, 6, 5)
+	orphanChild := childSpan(traceID, 7, 6)
+	otherTraceRoot := childSpan(model.NewTraceID(1, 2), 2, 0)
+	cycleA := childSpan(traceID, 8, 9)
+	cycleB := childSpan(traceID, 9, 8)
+
+	// descendants precede ancestors to verify that depth does not depend on order
+	addDepthTags([]*model.Batch{
+		{Spans: []*model.Span{grandChild, orphanChild, child}},
+		{Spans: []*model.Span{sibling, root, orphan, otherTraceRoot, cycleA, cycleB}},
+	})
+	for _, test := range []struct {
+		caption string
+		span    *model.Span
+		depth   int64
+	}{
+		{caption: "root", span: root, depth: 0},
+		{caption: "child", span: child, depth: 1},
+		{caption: "grand child", span: grandChild, depth: 2},
+		{caption: "sibling", span: sibling, depth: 1},
+		{caption: "orphan", span: orphan, depth: -1},
+		{caption: "child of orphan", span: orphanChild, depth: -1},
+		{caption: "root of other trace", span: otherTraceRoot, depth: 0},
+		{caption: "cycle", span: cycleA, depth: -1},
+	} {
+		assert.Equal(t, []model.KeyValue{model.Int64("jaeger.depth", test.depth)}, test.span.Tags, test.caption)
+	}
+}
+
+func TestConvert_depthTag(t *testing.T) {
+	span := func(id, parentID string) *tracev1.Span {
+		return &tracev1.Span{TraceId: testTraceID, SpanId: []byte(id), ParentSpanId: []byte(parentID)}
+	}
+	batches, err := newConverter(Options{DepthTag: true}).convert(tracesWithSpans(
+		span("0000000C", "0000000B"),
+		span("0000000B", "0000000A"),
+		span("0000000A", ""),
+		span("0000000E", "0000000D"),
+	))
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	var depths []int64
+	for _, span := range batches[0].Spans {
+		tag, ok := model.KeyValues(span.Tags).FindByKey("jaeger.depth")
+		require.True(t, ok)
+		depths = append(depths, tag.Int64())
+	}
+	assert.Equal(t, []int64{2, 1, 0, -1}, depths)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+
+	resourcev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.opentelemetry.io/collector/translator/conventions"
+)
+
+// withDeterministicIDs returns traces in which empty or zero trace and span IDs are replaced
+// by IDs derived from the service name, operation name and start time of the span.
+// Spans are copied before the IDs are replaced, the input traces are not modified.
+func withDeterministicIDs(td pdata.Traces) pdata.Traces {
+	needsIDs := false
+	forEachSpan(td, func(span pdata.Span) bool {
+		needsIDs = isPlaceholderID(span.TraceID().Bytes()) || isPlaceholderID(span.SpanID().Bytes())
+		return !needsIDs
+	})
+	if !needsIDs {
+		return td
+	}
+	orig := pdata.TracesToOtlp(td)
+	rss := make([]*tracev1.ResourceSpans, len(orig))
+	for i, rs := range orig {
+		if rs == nil {
+			continue
+		}
+		rsCopy := *rs
+		service := serviceName(rs.Resource)
+		rsCopy.InstrumentationLibrarySpans = make([]*tracev1.InstrumentationLibrarySpans, len(rs.InstrumentationLibrarySpans))
+		for j, ils := range rs.InstrumentationLibrarySpans {
+			if ils == nil {
+				continue
+			}
+			ilsCopy := *ils
+			ilsCopy.Spans = make([]*tracev1.Span, len(ils.Spans))
+			for k, span := range ils.Spans {
+				ilsCopy.Spans[k] = withSpanIDs(span, service)
+			}
+			rsCopy.InstrumentationLibrarySpans[j] = &ilsCopy
+		}
+		rss[i] = &rsCopy
+	}
+	return pdata.TracesFromOtlp(rss)
+}
+
+// withSpanIDs returns the span, or its copy with placeholder IDs replaced.
+func withSpanIDs(span *tracev1.Span, service string) *tracev1.Span {
+	if span == nil || (!isPlaceholderID(span.TraceId) && !isPlaceholderID(span.SpanId)) {
+		return span
+	}
+	spanCopy := *span
+	if isPlaceholderID(span.TraceId) {
+		spanCopy.TraceId = contentID(fnv.New128a(), service, span)
+	}
+	if isPlaceholderID(span.SpanId) {
+		spanCopy.SpanId = contentID(fnv.New64a(), service, span)
+	}
+	return &spanCopy
+}
+
+// contentID hashes the service name, operation name and start time of the span.
+func contentID(h hash.Hash, service string, span *tracev1.Span) []byte {
+	h.Write([]byte(service))
+	h.Write([]byte{0})
+	h.Write([]byte(span.Name))
+	h.Write([]byte{0})
+	var start [8]byte
+	binary.BigEndian.PutUint64(start[:], span.StartTimeUnixNano)
+	h.Write(start[:])
+	return h.Sum(nil)
+}
+
+func isPlaceholderID(id []byte) bool {
+	return len(id) == 0 || isZero(id)
+}
+
+func serviceName(resource *resourcev1.Resource) string {
+	if resource == nil {
+		return ""
+	}
+	for _, attr := range resource.Attributes {
+		if attr != nil && attr.Key == conventions.AttributeServiceName {
+			return attr.StringValue
+		}
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+
+	commonv1 "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	resourcev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+func placeholderTraces() pdata.Traces {
+	return pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+		Resource: &resourcev1.Resource{Attributes: []*commonv1.AttributeKeyValue{stringAttr("service.name", "frontend")}},
+		InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: []*tracev1.Span{
+			{Name: "get", StartTimeUnixNano: 1000},
+			{TraceId: make([]byte, 16), SpanId: make([]byte, 8), Name: "get", StartTimeUnixNano: 2000},
+			{TraceId: testTraceID, SpanId: testSpanID, Name: "get", StartTimeUnixNano: 1000},
+		}}},
+	}})
+}
+
+func convertIDs(t *testing.T, td pdata.Traces) []model.SpanRef {
+	batches, err := newConverter(Options{DeterministicIDs: true}).convert(td)
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	var ids []model.SpanRef
+	for _, span := range batches[0].Spans {
+		ids = append(ids, model.SpanRef{TraceID: span.TraceID, SpanID: span.SpanID})
+	}
+	return ids
+}
+
+func TestConvert_deterministicIDs(t *testing.T) {
+	td := placeholderTraces()
+	ids := convertIDs(t, td)
+	require.Len(t, ids, 3)
+	assert.Equal(t, ids, convertIDs(t, placeholderTraces()), "IDs are stable across runs")
+	assert.NotEqual(t, ids[0], ids[1], "IDs depend on start time")
+	for _, id := range ids[:2] {
+		assert.NotEqual(t, model.TraceID{}, id.TraceID)
+		assert.NotEqual(t, model.SpanID(0), id.SpanID)
+	}
+	assert.Equal(t, model.NewTraceID(0x3031323334353637, 0x3839616263646566), ids[2].TraceID, "valid IDs are kept")
+	assert.Equal(t, model.SpanID(0x3031323334353637), ids[2].SpanID)
+
+	assert.Empty(t, td.ResourceSpans().At(0).InstrumentationLibrarySpans().At(0).Spans().At(0).TraceID().Bytes(), "input is not modified")
+}
+
+func TestConvert_placeholderIDsRejectedByDefault(t *testing.T) {
+	_, err := newConverter(Options{}).convert(placeholderTraces())
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/jaegertracing/jaeger/model"
+)
+
+// DuplicateSpanMode controls handling of spans with the same trace and span ID within one push.
+type DuplicateSpanMode string
+
+const (
+	// DuplicateSpansKeep writes all duplicates.
+	DuplicateSpansKeep DuplicateSpanMode = ""
+	// DuplicateSpansDrop writes the first span and drops its duplicates.
+	DuplicateSpansDrop DuplicateSpanMode = "drop"
+	// DuplicateSpansMerge writes the first span with tags of its duplicates added
+	// unless the first span already has a tag with the same key.
+	DuplicateSpansMerge DuplicateSpanMode = "merge"
+)
+
+// removeDuplicates removes spans with the trace and span ID of a preceding span,
+// merging their tags into the preceding span if merge is true.
+// It returns the remaining spans and the number of removed spans.
+func removeDuplicates(spans []*model.Span, merge bool) ([]*model.Span, int) {
+	first := make(map[spanKey]*model.Span, len(spans))
+	kept := spans[:0]
+	for _, span := range spans {
+		key := spanKey{traceID: span.TraceID, spanID: span.SpanID}
+		original, ok := first[key]
+		if !ok {
+			first[key] = span
+			kept = append(kept, span)
+			continue
+		}
+		if merge {
+			mergeTags(original, span.Tags)
+		}
+	}
+	return kept, len(spans) - len(kept)
+}
+
+func mergeTags(span *model.Span, tags []model.KeyValue) {
+	for _, tag := range tags {
+		if _, ok := model.KeyValues(span.Tags).FindByKey(tag.Key); !ok {
+			span.Tags = append(span.Tags, tag)
+		}
+	}
+}
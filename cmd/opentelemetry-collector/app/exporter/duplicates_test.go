@@ -0,0 +1,77 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"testing"
+
+	commonv1 "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics/metricstest"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+func TestStore_duplicateSpans(t *testing.T) {
+	td := tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Attributes: []*commonv1.AttributeKeyValue{stringAttr("a", "1")}},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("76543210")},
+		&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Attributes: []*commonv1.AttributeKeyValue{stringAttr("a", "2"), stringAttr("b", "3")}},
+	)
+	tests := []struct {
+		caption    string
+		mode       DuplicateSpanMode
+		written    int
+		dropped    int
+		firstTags  []model.KeyValue
+		duplicates int
+	}{
+		{caption: "kept by default", written: 3, firstTags: []model.KeyValue{model.String("a", "1")}},
+		{caption: "dropped", mode: DuplicateSpansDrop, written: 2, dropped: 1, duplicates: 1, firstTags: []model.KeyValue{model.String("a", "1")}},
+		{caption: "merged", mode: DuplicateSpansMerge, written: 2, firstTags: []model.KeyValue{model.String("a", "1"), model.String("b", "3")}},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			writer := &recordingWriter{}
+			metricsFactory := metricstest.NewFactory(0)
+			s := newStorage(writer, Options{DuplicateSpans: test.mode, MetricsFactory: metricsFactory})
+			dropped, err := s.traceDataPusher(context.Background(), td)
+			require.NoError(t, err)
+			assert.Equal(t, test.dropped, dropped)
+			written := writer.written()
+			require.Len(t, written, test.written)
+			assert.Equal(t, test.firstTags, written[0].Tags)
+			metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{
+				Name:  "jaeger_exporter.spans.dropped",
+				Tags:  map[string]string{"reason": "intra_batch_dup"},
+				Value: test.duplicates,
+			})
+		})
+	}
+}
+
+func TestRemoveDuplicates_acrossTraces(t *testing.T) {
+	spans := []*model.Span{
+		childSpan(model.NewTraceID(1, 1), 1, 0),
+		childSpan(model.NewTraceID(1, 2), 1, 0),
+		childSpan(model.NewTraceID(1, 1), 1, 0),
+	}
+	kept, removed := removeDuplicates(spans, false)
+	assert.Equal(t, 1, removed)
+	assert.Len(t, kept, 2, "spans of different traces are not duplicates")
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	commonv1 "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	resourcev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics/metricstest"
+	"go.opentelemetry.io/collector/config/configmodels"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/plugin/storage/objectstore"
+)
+
+// bufferingWriter persists written spans only on Flush.
+type bufferingWriter struct {
+	mux       sync.Mutex
+	buffered  []*model.Span
+	persisted []*model.Span
+	flushErr  error
+}
+
+func (w *bufferingWriter) WriteSpan(span *model.Span) error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.buffered = append(w.buffered, span)
+	return nil
+}
+
+func (w *bufferingWriter) Flush(context.Context) error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.flushErr != nil {
+		return w.flushErr
+	}
+	w.persisted = append(w.persisted, w.buffered...)
+	w.buffered = nil
+	return nil
+}
+
+func (w *bufferingWriter) persistedSpans() int {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return len(w.persisted)
+}
+
+func TestStore_durableWritesWaitForWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s, writer := newWindowBatchingStorage(time.Second, 100, clock)
+	s.durable = true
+
+	acked := make(chan error, 1)
+	go func() {
+		_, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}))
+		acked <- err
+	}()
+	require.Eventually(t, func() bool {
+		s.batcher.mux.Lock()
+		defer s.batcher.mux.Unlock()
+		return len(s.batcher.spans) == 1
+	}, 5*time.Second, time.Millisecond)
+	select {
+	case <-acked:
+		t.Fatal("push returned before its window was written")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.advance(time.Second)
+	s.batcher.flushIfDue()
+	select {
+	case err := <-acked:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("push did not return after its window was written")
+	}
+	assert.Len(t, writer.written(), 1)
+}
+
+func TestStore_durableWritesReturnWindowError(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s := newStorage(spanWriter{err: errors.New("could not store")}, Options{DurableWrites: true})
+	s.batcher = newWindowBatcher(time.Second, 1, s.flushWindow, s.metrics.SpansPending[pendingQueueBatchWindow], clock.timeNow)
+
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Name: "error"}))
+	assert.EqualError(t, err, "could not store")
+	assert.Equal(t, 1, dropped)
+}
+
+func TestStore_durableWritesCancelledWait(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s, _ := newWindowBatchingStorage(time.Second, 100, clock)
+	s.durable = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dropped, err := s.traceDataPusher(ctx, tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, dropped)
+}
+
+func TestStore_durableWritesFlushWriter(t *testing.T) {
+	writer := &bufferingWriter{}
+	s := newStorage(writer, Options{DurableWrites: true})
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}))
+	require.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	assert.Equal(t, 1, writer.persistedSpans(), "spans are persisted when the push returns")
+
+	writer.flushErr = errors.New("could not flush")
+	dropped, err = s.traceDataPusher(context.Background(), tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("76543210")},
+	))
+	assert.EqualError(t, err, "could not flush")
+	assert.Equal(t, 2, dropped)
+	assert.Equal(t, 1, writer.persistedSpans())
+}
+
+func TestStore_durableWritesCountFlushedSpansOnly(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	metricsFactory := metricstest.NewFactory(0)
+	writer := &bufferingWriter{}
+	s := newStorage(writer, Options{DurableWrites: true, LogShutdownSummary: true, Logger: zap.New(core), MetricsFactory: metricsFactory})
+	pushSpans(t, s, "00000001")
+
+	writer.flushErr = errors.New("could not flush")
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000002")},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000003")},
+	))
+	require.Error(t, err)
+	metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{Name: "jaeger_exporter.spans.written", Value: 1})
+
+	require.NoError(t, s.shutdown(context.Background()))
+	entries := logs.FilterMessage("Span writer exporter summary").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, map[string]interface{}{
+		"written":  int64(1),
+		"failed":   int64(2),
+		"dropped":  int64(0),
+		"filtered": int64(0),
+	}, entries[0].ContextMap())
+}
+
+// partitionedStore fails uploads of objects of the "unavailable" service.
+type partitionedStore struct {
+	mux     sync.Mutex
+	objects int
+}
+
+func (s *partitionedStore) PutObject(_ context.Context, key string, _ []byte) error {
+	// slow uploads let pushes overlap
+	time.Sleep(time.Millisecond)
+	if strings.Contains(key, "service=unavailable/") {
+		return errors.New("partition unavailable")
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.objects++
+	return nil
+}
+
+func TestStore_durableWritesOfConcurrentPushes(t *testing.T) {
+	store := &partitionedStore{}
+	writer := objectstore.NewSpanWriter(store, zap.NewNop(), objectstore.FlushInterval(time.Hour))
+	s := newStorage(writer, Options{DurableWrites: true})
+	defer s.shutdown(context.Background())
+
+	const pushes = 20
+	errs := make([]error, pushes)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < pushes; i++ {
+		service := "frontend"
+		if i%2 == 1 {
+			service = "unavailable"
+		}
+		td := pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+			Resource: &resourcev1.Resource{Attributes: []*commonv1.AttributeKeyValue{stringAttr("service.name", service)}},
+			InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: []*tracev1.Span{
+				{TraceId: testTraceID, SpanId: testSpanID},
+			}}},
+		}})
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = s.traceDataPusher(context.Background(), td)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	for i, err := range errs {
+		if i%2 == 1 {
+			assert.Error(t, err, "push %d of the unavailable partition must fail", i)
+		} else {
+			assert.NoError(t, err, "push %d must not fail because of other pushes", i)
+		}
+	}
+	assert.Equal(t, pushes/2, store.objects, "each successful push is flushed by itself")
+}
+
+func TestStore_durableWritesNotRecordedInManifestOnFlushError(t *testing.T) {
+	writer := &bufferingWriter{flushErr: errors.New("could not flush")}
+	manifests := &recordingManifestWriter{}
+	s := newStorage(writer, Options{DurableWrites: true, ManifestWriter: manifests})
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}))
+	assert.EqualError(t, err, "could not flush")
+	assert.Empty(t, manifests.manifests)
+
+	writer.flushErr = nil
+	_, err = s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}))
+	require.NoError(t, err)
+	require.Len(t, manifests.manifests, 1)
+	assert.Len(t, manifests.manifests[0].Spans, 1)
+}
+
+func TestStore_durableWritesWarnWithoutDurableWriter(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	newStorage(&recordingWriter{}, Options{DurableWrites: true, Logger: zap.New(core)})
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "Span writer does not implement DurableWriter, durable writes assume spans are persisted once written", logs.All()[0].Message)
+
+	newStorage(&bufferingWriter{}, Options{DurableWrites: true, Logger: zap.New(core)})
+	newStorage(&recordingWriter{}, Options{Logger: zap.New(core)})
+	assert.Equal(t, 1, logs.Len())
+}
+
+func TestStore_writerNotFlushedByDefault(t *testing.T) {
+	writer := &bufferingWriter{}
+	s := newStorage(writer, Options{})
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}))
+	require.NoError(t, err)
+	assert.Equal(t, 0, writer.persistedSpans())
+}
+
+func TestNew_durableWritesWithPartialSpans(t *testing.T) {
+	exporter, err := NewSpanWriterExporter(&configmodels.ExporterSettings{}, mockStorageFactory{spanWriter: spanWriter{}}, Options{DurableWrites: true, PartialSpanWindow: time.Second})
+	require.Nil(t, exporter)
+	assert.Equal(t, errDurablePartialSpans, err)
+}
@@ -67,4 +67,5 @@ func TestLoadConfigAndFlags(t *testing.T) {
 	assert.Equal(t, "/superstore", grpcCfg.PluginBinary)
 	assert.Equal(t, "info", grpcCfg.PluginLogLevel)
 	assert.Equal(t, "/doesnt/exist", grpcCfg.PluginConfigurationFile)
+	assert.Equal(t, true, cfg.SpanWriter.SanitizeNames)
 }
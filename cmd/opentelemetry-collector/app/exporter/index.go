@@ -0,0 +1,118 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+)
+
+var errIndexedWriterRequired = errors.New("index template requires the span writer to implement IndexedWriter")
+
+// IndexedWriter writes spans to an index chosen by the exporter.
+type IndexedWriter interface {
+	WriteSpanToIndex(span *model.Span, index string) error
+}
+
+var indexDateTokens = strings.NewReplacer("yyyy", "2006", "MM", "01", "dd", "02", "HH", "15")
+
+// indexTemplate renders index names of spans.
+type indexTemplate struct {
+	parts []indexTemplatePart
+}
+
+// indexTemplatePart is either literal text, the service name or a date layout.
+type indexTemplatePart struct {
+	literal    string
+	service    bool
+	dateLayout string
+}
+
+// parseIndexTemplate parses templates like "jaeger-span-{service}-{yyyy.MM.dd}". Date placeholders
+// consist of tokens yyyy, MM, dd and HH, optionally separated by '.', '-' or '_'.
+func parseIndexTemplate(template string) (*indexTemplate, error) {
+	t := &indexTemplate{}
+	for rest := template; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			t.parts = append(t.parts, indexTemplatePart{literal: rest})
+			break
+		}
+		if open > 0 {
+			t.parts = append(t.parts, indexTemplatePart{literal: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder in index template %q", template)
+		}
+		placeholder := rest[open+1 : open+end]
+		rest = rest[open+end+1:]
+		if placeholder == "service" {
+			t.parts = append(t.parts, indexTemplatePart{service: true})
+			continue
+		}
+		layout := indexDateTokens.Replace(placeholder)
+		if strings.Trim(layout, "0123456789.-_") != "" || layout == placeholder {
+			return nil, fmt.Errorf("unknown placeholder {%s} in index template %q", placeholder, template)
+		}
+		t.parts = append(t.parts, indexTemplatePart{dateLayout: layout})
+	}
+	return t, nil
+}
+
+// render returns the index name of the span. The service name is lowercased as required
+// by Elasticsearch, the date is the start time of the span in UTC.
+func (t *indexTemplate) render(span *model.Span) string {
+	var sb strings.Builder
+	for _, part := range t.parts {
+		switch {
+		case part.service:
+			if span.Process != nil {
+				sb.WriteString(strings.ToLower(span.Process.ServiceName))
+			}
+		case part.dateLayout != "":
+			sb.WriteString(span.StartTime.UTC().Format(part.dateLayout))
+		default:
+			sb.WriteString(part.literal)
+		}
+	}
+	return sb.String()
+}
+
+// indexedWrites routes spans to the indices rendered from the template.
+type indexedWrites struct {
+	template *indexTemplate
+	writer   IndexedWriter
+}
+
+func newIndexedWrites(template string, writer spanstore.Writer) (*indexedWrites, error) {
+	indexedWriter, ok := writer.(IndexedWriter)
+	if !ok {
+		return nil, errIndexedWriterRequired
+	}
+	t, err := parseIndexTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	return &indexedWrites{template: t, writer: indexedWriter}, nil
+}
+
+func (w *indexedWrites) writeSpan(span *model.Span) error {
+	return w.writer.WriteSpanToIndex(span, w.template.render(span))
+}
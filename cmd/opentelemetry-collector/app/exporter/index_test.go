@@ -0,0 +1,99 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/config/configmodels"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+type indexedWriter struct {
+	spanWriter
+	mux     sync.Mutex
+	indices []string
+}
+
+func (w *indexedWriter) WriteSpanToIndex(span *model.Span, index string) error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.indices = append(w.indices, index)
+	return nil
+}
+
+func TestIndexTemplate_render(t *testing.T) {
+	span := &model.Span{
+		StartTime: time.Date(2020, time.June, 7, 23, 30, 0, 0, time.FixedZone("", -2*60*60)),
+		Process:   &model.Process{ServiceName: "Frontend"},
+	}
+	tests := []struct {
+		template string
+		index    string
+	}{
+		{template: "jaeger-span-{service}-{yyyy.MM.dd}", index: "jaeger-span-frontend-2020.06.08"},
+		{template: "jaeger-span-{yyyy-MM-dd_HH}", index: "jaeger-span-2020-06-08_01"},
+		{template: "{service}", index: "frontend"},
+		{template: "jaeger-span", index: "jaeger-span"},
+	}
+	for _, test := range tests {
+		t.Run(test.template, func(t *testing.T) {
+			template, err := parseIndexTemplate(test.template)
+			require.NoError(t, err)
+			assert.Equal(t, test.index, template.render(span))
+		})
+	}
+}
+
+func TestIndexTemplate_invalid(t *testing.T) {
+	_, err := parseIndexTemplate("jaeger-span-{yyyy.MM.dd")
+	assert.EqualError(t, err, `unclosed placeholder in index template "jaeger-span-{yyyy.MM.dd"`)
+	_, err = parseIndexTemplate("jaeger-span-{operation}")
+	assert.EqualError(t, err, `unknown placeholder {operation} in index template "jaeger-span-{operation}"`)
+	_, err = parseIndexTemplate("jaeger-span-{yyyy:MM}")
+	assert.EqualError(t, err, `unknown placeholder {yyyy:MM} in index template "jaeger-span-{yyyy:MM}"`)
+}
+
+func TestStore_writesToIndex(t *testing.T) {
+	writer := &indexedWriter{}
+	s := newStorage(writer, Options{})
+	var err error
+	s.indexed, err = newIndexedWrites("jaeger-span-{yyyy.MM.dd}", writer)
+	require.NoError(t, err)
+	_, err = s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{
+		TraceId:           testTraceID,
+		SpanId:            testSpanID,
+		StartTimeUnixNano: uint64(time.Date(2020, time.June, 7, 12, 0, 0, 0, time.UTC).UnixNano()),
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"jaeger-span-2020.06.07"}, writer.indices)
+}
+
+func TestNew_indexTemplateRequiresIndexedWriter(t *testing.T) {
+	exporter, err := NewSpanWriterExporter(&configmodels.ExporterSettings{}, mockStorageFactory{spanWriter: spanWriter{}}, Options{IndexTemplate: "jaeger-span-{service}"})
+	require.Nil(t, exporter)
+	assert.Equal(t, errIndexedWriterRequired, err)
+
+	exporter, err = NewSpanWriterExporter(&configmodels.ExporterSettings{}, mockStorageFactory{spanWriter: &indexedWriter{}}, Options{IndexTemplate: "jaeger-span-{service}"})
+	require.NoError(t, err)
+	assert.NoError(t, exporter.Shutdown(context.Background()))
+}
@@ -17,6 +17,7 @@ package kafka
 import (
 	"go.opentelemetry.io/collector/config/configmodels"
 
+	storageOtelExporter "github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter"
 	"github.com/jaegertracing/jaeger/plugin/storage/kafka"
 )
 
@@ -24,4 +25,5 @@ import (
 type Config struct {
 	configmodels.ExporterSettings `mapstructure:",squash"` // squash ensures fields are correctly decoded in embedded struct.
 	kafka.Options                 `mapstructure:",squash"`
+	SpanWriter                    storageOtelExporter.Options `mapstructure:",squash"`
 }
@@ -17,6 +17,7 @@ package kafka
 import (
 	"path"
 	"testing"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/stretchr/testify/assert"
@@ -48,6 +49,7 @@ func TestDefaultConfig(t *testing.T) {
 	assert.Equal(t, "/etc/krb5.conf", defaultCfg.Config.Kerberos.ConfigPath)
 	assert.Equal(t, "kafka", defaultCfg.Config.Kerberos.ServiceName)
 	assert.Equal(t, false, defaultCfg.Config.TLS.Enabled)
+	assert.Equal(t, true, defaultCfg.PartitionByTraceID)
 }
 
 func TestLoadConfigAndFlags(t *testing.T) {
@@ -90,4 +92,5 @@ func TestLoadConfigAndFlags(t *testing.T) {
 	assert.Equal(t, "jaeger", kafkaCfg.Config.Kerberos.Realm)
 	assert.Equal(t, "/etc/foo", kafkaCfg.Config.Kerberos.ConfigPath)
 	assert.Equal(t, "from-jaeger-config", kafkaCfg.Config.Kerberos.Username)
+	assert.Equal(t, 2*time.Second, kafkaCfg.SpanWriter.BatchWindow)
 }
@@ -30,5 +30,8 @@ func New(config *Config, params component.ExporterCreateParams) (component.Trace
 	if err != nil {
 		return nil, err
 	}
-	return storageOtelExporter.NewSpanWriterExporter(config, f)
+	opts := config.SpanWriter
+	opts.Logger = params.Logger
+	opts.MetricsFactory = storageOtelExporter.MetricsFactory(config.Name())
+	return storageOtelExporter.NewSpanWriterExporter(config, f, opts)
 }
@@ -0,0 +1,76 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+// BatchManifest lists spans stored by one write of the exporter, for reconciliation with external systems.
+type BatchManifest struct {
+	// BatchID uniquely identifies the batch within the exporter's process.
+	BatchID string
+	// Timestamp is the time at which the batch finished writing.
+	Timestamp time.Time
+	// Spans identifies the spans which were written successfully.
+	Spans []SpanRef
+}
+
+// SpanRef identifies a span.
+type SpanRef struct {
+	TraceID model.TraceID
+	SpanID  model.SpanID
+}
+
+// ManifestWriter receives a manifest of each batch written by the exporter.
+type ManifestWriter interface {
+	WriteManifest(manifest *BatchManifest) error
+}
+
+// manifestRecorder assigns IDs to batches and writes their manifests.
+type manifestRecorder struct {
+	writer   ManifestWriter
+	idPrefix string
+	sequence uint64
+	timeNow  func() time.Time
+}
+
+func newManifestRecorder(writer ManifestWriter, timeNow func() time.Time) *manifestRecorder {
+	return &manifestRecorder{
+		writer:   writer,
+		idPrefix: fmt.Sprintf("%x", timeNow().UnixNano()),
+		timeNow:  timeNow,
+	}
+}
+
+// record writes the manifest of written spans, batches without written spans are skipped.
+func (r *manifestRecorder) record(written []*model.Span) error {
+	if len(written) == 0 {
+		return nil
+	}
+	manifest := &BatchManifest{
+		BatchID:   fmt.Sprintf("%s-%d", r.idPrefix, atomic.AddUint64(&r.sequence, 1)),
+		Timestamp: r.timeNow(),
+		Spans:     make([]SpanRef, len(written)),
+	}
+	for i, span := range written {
+		manifest.Spans[i] = SpanRef{TraceID: span.TraceID, SpanID: span.SpanID}
+	}
+	return r.writer.WriteManifest(manifest)
+}
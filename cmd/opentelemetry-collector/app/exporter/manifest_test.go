@@ -0,0 +1,119 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+type recordingManifestWriter struct {
+	manifests []*BatchManifest
+	err       error
+}
+
+func (w *recordingManifestWriter) WriteManifest(manifest *BatchManifest) error {
+	w.manifests = append(w.manifests, manifest)
+	return w.err
+}
+
+func TestStore_batchManifest(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	manifests := &recordingManifestWriter{}
+	s := newStorage(spanWriter{err: errors.New("could not store")}, Options{})
+	s.manifests = newManifestRecorder(manifests, clock.timeNow)
+	td := tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000001")},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000002"), Name: "error"},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000003")},
+	)
+
+	clock.advance(time.Second)
+	dropped, err := s.traceDataPusher(context.Background(), td)
+	require.Error(t, err)
+	assert.Equal(t, 1, dropped)
+	clock.advance(time.Second)
+	s.traceDataPusher(context.Background(), td)
+
+	traceID := model.NewTraceID(0x3031323334353637, 0x3839616263646566)
+	require.Len(t, manifests.manifests, 2)
+	assert.Equal(t, &BatchManifest{
+		BatchID:   "0-1",
+		Timestamp: time.Unix(1, 0),
+		Spans: []SpanRef{
+			{TraceID: traceID, SpanID: model.SpanID(0x3030303030303031)},
+			{TraceID: traceID, SpanID: model.SpanID(0x3030303030303033)},
+		},
+	}, manifests.manifests[0], "failed spans are not listed")
+	assert.Equal(t, "0-2", manifests.manifests[1].BatchID)
+	assert.Equal(t, time.Unix(2, 0), manifests.manifests[1].Timestamp)
+}
+
+func TestStore_batchManifestError(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	manifests := &recordingManifestWriter{err: errors.New("side store unavailable")}
+	s := newStorage(spanWriter{err: errors.New("could not store")}, Options{Logger: zap.New(core), ManifestWriter: manifests})
+
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}))
+	require.NoError(t, err, "spans were stored")
+	assert.Equal(t, 0, dropped)
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, "Failed to write batch manifest", logs.All()[0].Message)
+
+	s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Name: "error"}))
+	assert.Len(t, manifests.manifests, 1, "batches without stored spans have no manifest")
+}
+
+// closingWriter counts how often it was closed.
+type closingWriter struct {
+	recordingWriter
+	recordingManifestWriter
+	closed int
+}
+
+func (w *closingWriter) Close() error {
+	w.closed++
+	return nil
+}
+
+func TestStore_shutdownClosesManifestWriter(t *testing.T) {
+	manifests := &closingWriter{}
+	s := newStorage(&recordingWriter{}, Options{ManifestWriter: manifests})
+	require.NoError(t, s.shutdown(context.Background()))
+	assert.Equal(t, 1, manifests.closed)
+}
+
+func TestStore_shutdownClosesSharedWriterOnce(t *testing.T) {
+	writer := &closingWriter{}
+	s := newStorage(writer, Options{
+		RootSpanWriter:   writer,
+		MirrorWriter:     writer,
+		MirrorPercentage: 100,
+		ManifestWriter:   writer,
+	})
+	pushSpans(t, s, "00000001")
+	require.NoError(t, s.shutdown(context.Background()))
+	assert.Equal(t, 1, writer.closed)
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sync"
+
+	"github.com/uber/jaeger-lib/metrics"
+	jexpvar "github.com/uber/jaeger-lib/metrics/expvar"
+	"github.com/uber/jaeger-lib/metrics/multi"
+	jprometheus "github.com/uber/jaeger-lib/metrics/prometheus"
+)
+
+const (
+	metricsNamespace = "jaeger_exporter"
+
+	// dropReasonTraceSpanCap is used for spans exceeding the per-trace span cap.
+	dropReasonTraceSpanCap = "trace_span_cap"
+	// dropReasonParentDropped is used for spans whose parent was dropped in the same push.
+	dropReasonParentDropped = "parent_dropped"
+	// dropReasonContextCancelled is used for spans not written because the push context was cancelled.
+	dropReasonContextCancelled = "context_cancelled"
+	// dropReasonInternalFiltered is used for spans of INTERNAL kind dropped by the internal span filter.
+	dropReasonInternalFiltered = "internal_filtered"
+	// dropReasonIntraBatchDuplicate is used for spans with the trace and span ID of another span in the same push.
+	dropReasonIntraBatchDuplicate = "intra_batch_dup"
+	// dropReasonSchemaViolation is used for spans whose process lacks a process tag required by its service.
+	dropReasonSchemaViolation = "schema_violation"
+	// dropReasonBeyondRetention is used for spans which started before the storage retention period.
+	dropReasonBeyondRetention = "beyond_retention"
+	// dropReasonGlobalRateLimited is used for spans shed by the global write rate limit.
+	dropReasonGlobalRateLimited = "global_rate_limited"
+)
+
+const (
+	// pendingQueueBatchWindow is used for spans held by the window batcher until the window is flushed.
+	pendingQueueBatchWindow = "batch_window"
+	// pendingQueuePartialSpans is used for partial spans held until another part of the span arrives.
+	pendingQueuePartialSpans = "partial_spans"
+)
+
+// dropReasons lists all reasons for which the exporter drops spans.
+var dropReasons = []string{
+	dropReasonTraceSpanCap,
+	dropReasonParentDropped,
+	dropReasonContextCancelled,
+	dropReasonInternalFiltered,
+	dropReasonIntraBatchDuplicate,
+	dropReasonSchemaViolation,
+	dropReasonBeyondRetention,
+	dropReasonGlobalRateLimited,
+}
+
+// storageMetrics contains metrics reported by the span writer exporter.
+type storageMetrics struct {
+	// SpansDropped counts spans which were not written, by drop reason
+	SpansDropped map[string]metrics.Counter
+	// SpansConversionFailed counts spans of batches rejected by the translator, by failure cause
+	SpansConversionFailed map[string]metrics.Counter
+	// SpansWritten counts spans written successfully
+	SpansWritten metrics.Counter
+	// WriteTimeouts counts writes of pushes or batch windows which exceeded the write timeout
+	WriteTimeouts metrics.Counter
+	// SpansPending reports the number of spans held by the exporter and not written yet, by queue
+	SpansPending map[string]metrics.Gauge
+}
+
+// expvar variables can be published only once per process,
+// therefore all exporters share one expvar factory, which caches created metrics.
+var expvarMetrics struct {
+	once    sync.Once
+	factory metrics.Factory
+}
+
+// Prometheus metrics can be registered only once per process,
+// therefore all storage exporters share one Prometheus factory, which caches created metrics.
+var prometheusMetrics struct {
+	once    sync.Once
+	factory metrics.Factory
+}
+
+// MetricsFactory returns the factory for metrics of the storage exporter with the given name,
+// registered with the default Prometheus registerer. Metrics of exporters are distinguished
+// by the "exporter" tag.
+func MetricsFactory(exporterName string) metrics.Factory {
+	prometheusMetrics.once.Do(func() {
+		prometheusMetrics.factory = jprometheus.New()
+	})
+	return prometheusMetrics.factory.Namespace(metrics.NSOptions{Tags: map[string]string{"exporter": exporterName}})
+}
+
+// exporterMetricsFactory returns the factory for exporter metrics,
+// which also publishes the metrics as expvar variables if enabled.
+func exporterMetricsFactory(opts Options) metrics.Factory {
+	factory := namespacedFactory(opts.MetricsFactory)
+	if !opts.ExpvarMetrics {
+		return factory
+	}
+	expvarMetrics.once.Do(func() {
+		expvarMetrics.factory = namespacedFactory(jexpvar.NewFactory(10))
+	})
+	return multi.New(factory, expvarMetrics.factory)
+}
+
+// namespacedFactory returns the factory for exporter metrics.
+func namespacedFactory(factory metrics.Factory) metrics.Factory {
+	if factory == nil {
+		return metrics.NullFactory
+	}
+	return factory.Namespace(metrics.NSOptions{Name: metricsNamespace})
+}
+
+func newStorageMetrics(factory metrics.Factory) storageMetrics {
+	m := storageMetrics{
+		SpansDropped:          make(map[string]metrics.Counter, len(dropReasons)),
+		SpansConversionFailed: make(map[string]metrics.Counter, len(conversionFailureCauses)),
+		SpansWritten:          factory.Counter(metrics.Options{Name: "spans.written"}),
+		WriteTimeouts:         factory.Counter(metrics.Options{Name: "write_timeouts"}),
+		SpansPending:          make(map[string]metrics.Gauge, 2),
+	}
+	for _, queue := range []string{pendingQueueBatchWindow, pendingQueuePartialSpans} {
+		m.SpansPending[queue] = factory.Gauge(metrics.Options{Name: "spans.pending", Tags: map[string]string{"queue": queue}})
+	}
+	for _, reason := range dropReasons {
+		m.SpansDropped[reason] = factory.Counter(metrics.Options{Name: "spans.dropped", Tags: map[string]string{"reason": reason}})
+	}
+	for _, cause := range conversionFailureCauses {
+		m.SpansConversionFailed[cause] = factory.Counter(metrics.Options{Name: "spans.conversion_failed", Tags: map[string]string{"cause": cause}})
+	}
+	return m
+}
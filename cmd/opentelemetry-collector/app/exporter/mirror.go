@@ -0,0 +1,99 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sync"
+
+	"github.com/uber/jaeger-lib/metrics"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+)
+
+// mirrorBuckets is the resolution of the mirrored percentage of traces.
+const mirrorBuckets = 10000
+
+const defaultMirrorQueueSize = 1000
+
+// mirror writes spans of a percentage of traces to a secondary writer.
+// Spans are queued and written in the background, so that latency of the secondary writer
+// does not affect pushes. Spans are dropped when the queue is full.
+// Results of mirrored writes are only reported in metrics.
+type mirror struct {
+	writer    spanstore.Writer
+	threshold uint64
+	queue     chan *model.Span
+	done      chan struct{}
+	closeOnce sync.Once
+	mirrored  metrics.Counter
+	failed    metrics.Counter
+	dropped   metrics.Counter
+}
+
+func newMirror(writer spanstore.Writer, percentage float64, queueSize int, factory metrics.Factory) *mirror {
+	if queueSize <= 0 {
+		queueSize = defaultMirrorQueueSize
+	}
+	m := &mirror{
+		writer:    writer,
+		threshold: uint64(percentage / 100 * mirrorBuckets),
+		queue:     make(chan *model.Span, queueSize),
+		done:      make(chan struct{}),
+		mirrored:  factory.Counter(metrics.Options{Name: "spans.mirrored", Tags: map[string]string{"result": "ok"}}),
+		failed:    factory.Counter(metrics.Options{Name: "spans.mirrored", Tags: map[string]string{"result": "failed"}}),
+		dropped:   factory.Counter(metrics.Options{Name: "spans.mirrored", Tags: map[string]string{"result": "dropped"}}),
+	}
+	go m.run()
+	return m
+}
+
+// selected returns true for traces which are mirrored.
+// The decision depends only on the trace ID, so that whole traces are mirrored.
+func (m *mirror) selected(traceID model.TraceID) bool {
+	return traceID.Low%mirrorBuckets < m.threshold
+}
+
+// write queues the span for the secondary writer if its trace is mirrored.
+// It must not be called after close.
+func (m *mirror) write(span *model.Span) {
+	if !m.selected(span.TraceID) {
+		return
+	}
+	select {
+	case m.queue <- span:
+	default:
+		m.dropped.Inc(1)
+	}
+}
+
+func (m *mirror) run() {
+	defer close(m.done)
+	for span := range m.queue {
+		if err := m.writer.WriteSpan(span); err != nil {
+			m.failed.Inc(1)
+			continue
+		}
+		m.mirrored.Inc(1)
+	}
+}
+
+// close waits until queued spans are written.
+func (m *mirror) close() {
+	m.closeOnce.Do(func() {
+		close(m.queue)
+	})
+	<-m.done
+}
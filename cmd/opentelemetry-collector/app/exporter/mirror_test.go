@@ -0,0 +1,159 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics"
+	"github.com/uber/jaeger-lib/metrics/metricstest"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+func TestMirror_percentage(t *testing.T) {
+	m := newMirror(&recordingWriter{}, 25, 0, metrics.NullFactory)
+	selected := 0
+	for i := uint64(0); i < 10000; i++ {
+		if m.selected(model.NewTraceID(i, i*7919)) {
+			selected++
+		}
+	}
+	assert.InDelta(t, 2500, selected, 100)
+	assert.True(t, m.selected(model.NewTraceID(0, 2499)))
+	assert.False(t, m.selected(model.NewTraceID(0, 2500)))
+
+	assert.False(t, newMirror(&recordingWriter{}, 0, 0, metrics.NullFactory).selected(model.NewTraceID(0, 0)))
+	assert.True(t, newMirror(&recordingWriter{}, 100, 0, metrics.NullFactory).selected(model.NewTraceID(0, 9999)))
+}
+
+func TestMirror_optionsFromConfig(t *testing.T) {
+	opts := loadOptions(t)
+	assert.Equal(t, 12.5, opts.MirrorPercentage)
+	assert.Equal(t, 100, opts.MirrorQueueSize)
+}
+
+func TestStore_mirrorWholeTraces(t *testing.T) {
+	primary, mirrored := &recordingWriter{}, &recordingWriter{}
+	s := newStorage(primary, Options{MirrorWriter: mirrored, MirrorPercentage: 50})
+	span := func(traceIDLow uint64, spanID byte) *tracev1.Span {
+		traceID := make([]byte, 16)
+		binary.BigEndian.PutUint64(traceID[:8], 1)
+		binary.BigEndian.PutUint64(traceID[8:], traceIDLow)
+		return &tracev1.Span{TraceId: traceID, SpanId: []byte{0, 0, 0, 0, 0, 0, 0, spanID}}
+	}
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(
+		span(10, 1), span(6000, 2), span(10, 3), span(6000, 4),
+	))
+	require.NoError(t, err)
+	require.NoError(t, s.shutdown(context.Background()))
+	assert.Len(t, primary.written(), 4)
+	var mirroredIDs []model.SpanID
+	for _, span := range mirrored.written() {
+		assert.Equal(t, uint64(10), span.TraceID.Low)
+		mirroredIDs = append(mirroredIDs, span.SpanID)
+	}
+	assert.Equal(t, []model.SpanID{1, 3}, mirroredIDs)
+}
+
+func TestStore_mirrorFailureIsolation(t *testing.T) {
+	primary := &recordingWriter{}
+	metricsFactory := metricstest.NewFactory(0)
+	s := newStorage(primary, Options{
+		MirrorWriter:     spanWriter{err: errors.New("mirror unavailable")},
+		MirrorPercentage: 100,
+		MetricsFactory:   metricsFactory,
+	})
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Name: "error"},
+		&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID},
+	))
+	require.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	assert.Len(t, primary.written(), 2)
+	require.NoError(t, s.shutdown(context.Background()))
+	metricsFactory.AssertCounterMetrics(t,
+		metricstest.ExpectedMetric{Name: "jaeger_exporter.spans.mirrored", Tags: map[string]string{"result": "ok"}, Value: 1},
+		metricstest.ExpectedMetric{Name: "jaeger_exporter.spans.mirrored", Tags: map[string]string{"result": "failed"}, Value: 1},
+	)
+}
+
+func TestStore_mirrorOutsideWriteTimeout(t *testing.T) {
+	primary := &recordingWriter{}
+	metricsFactory := metricstest.NewFactory(0)
+	s := newStorage(primary, Options{
+		MirrorWriter:     sleepingWriter{delay: 20 * time.Millisecond},
+		MirrorPercentage: 100,
+		WriteTimeout:     10 * time.Millisecond,
+		MetricsFactory:   metricsFactory,
+	})
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000001")},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000002")},
+	))
+	require.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	assert.Len(t, primary.written(), 2)
+	require.NoError(t, s.shutdown(context.Background()))
+	metricsFactory.AssertCounterMetrics(t,
+		metricstest.ExpectedMetric{Name: "jaeger_exporter.write_timeouts", Value: 0},
+		metricstest.ExpectedMetric{Name: "jaeger_exporter.spans.mirrored", Tags: map[string]string{"result": "ok"}, Value: 2},
+	)
+}
+
+func TestStore_mirrorQueueOverflow(t *testing.T) {
+	primary := &recordingWriter{}
+	mirrored := &blockingWriter{started: make(chan struct{}, 1), release: make(chan struct{})}
+	metricsFactory := metricstest.NewFactory(0)
+	s := newStorage(primary, Options{
+		MirrorWriter:     mirrored,
+		MirrorPercentage: 100,
+		MirrorQueueSize:  1,
+		MetricsFactory:   metricsFactory,
+	})
+	pushSpans(t, s, "00000001")
+	<-mirrored.started
+	// pushes do not wait for the blocked mirror writer, spans beyond the queue are dropped
+	pushSpans(t, s, "00000002", "00000003", "00000004")
+	assert.Len(t, primary.written(), 4)
+	close(mirrored.release)
+	require.NoError(t, s.shutdown(context.Background()))
+	metricsFactory.AssertCounterMetrics(t,
+		metricstest.ExpectedMetric{Name: "jaeger_exporter.spans.mirrored", Tags: map[string]string{"result": "ok"}, Value: 2},
+		metricstest.ExpectedMetric{Name: "jaeger_exporter.spans.mirrored", Tags: map[string]string{"result": "dropped"}, Value: 2},
+	)
+}
+
+// blockingWriter signals started on its first write and blocks writes until release is closed.
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) WriteSpan(span *model.Span) error {
+	select {
+	case w.started <- struct{}{}:
+	default:
+	}
+	<-w.release
+	return nil
+}
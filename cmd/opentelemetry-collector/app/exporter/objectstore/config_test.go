@@ -0,0 +1,85 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/config"
+	"go.opentelemetry.io/collector/config/configcheck"
+
+	"github.com/jaegertracing/jaeger/cmd/flags"
+	jConfig "github.com/jaegertracing/jaeger/pkg/config"
+	"github.com/jaegertracing/jaeger/plugin/storage/objectstore"
+)
+
+func TestDefaultConfig(t *testing.T) {
+	v, c := jConfig.Viperize(DefaultOptions().AddFlags)
+	err := c.ParseFlags([]string{""})
+	require.NoError(t, err)
+	factory := &Factory{OptionsFactory: func() *objectstore.Options {
+		opts := DefaultOptions()
+		opts.InitFromViper(v)
+		return opts
+	}}
+	defaultCfg := factory.CreateDefaultConfig().(*Config)
+	assert.NoError(t, configcheck.ValidateConfig(defaultCfg))
+	assert.Equal(t, "us-east-1", defaultCfg.S3.Region)
+	assert.Equal(t, "", defaultCfg.S3.Bucket)
+	assert.Equal(t, time.Minute, defaultCfg.FlushInterval)
+	assert.Equal(t, 10000, defaultCfg.MaxBufferedSpans)
+	assert.Equal(t, false, defaultCfg.Gzip)
+}
+
+func TestLoadConfigAndFlags(t *testing.T) {
+	factories, err := config.ExampleComponents()
+	require.NoError(t, err)
+
+	v, c := jConfig.Viperize(DefaultOptions().AddFlags, flags.AddConfigFileFlag)
+	err = c.ParseFlags([]string{"--config-file=./testdata/jaeger-config.yaml", "--object-store.bucket=jaeger-test"})
+	require.NoError(t, err)
+
+	err = flags.TryLoadConfigFile(v)
+	require.NoError(t, err)
+
+	factory := &Factory{OptionsFactory: func() *objectstore.Options {
+		opts := DefaultOptions()
+		opts.InitFromViper(v)
+		assert.Equal(t, "jaeger-test", opts.S3.Bucket)
+		assert.Equal(t, "from-jaeger-config", opts.KeyPrefix)
+		return opts
+	}}
+
+	factories.Exporters[TypeStr] = factory
+	cfg, err := config.LoadConfigFile(t, path.Join(".", "testdata", "config.yaml"), factories)
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+
+	objectStoreCfg := cfg.Exporters[TypeStr].(*Config)
+	assert.Equal(t, TypeStr, objectStoreCfg.Name())
+	assert.Equal(t, "http://127.0.0.1:9000", objectStoreCfg.S3.Endpoint)
+	assert.Equal(t, "traces", objectStoreCfg.S3.Bucket)
+	assert.Equal(t, true, objectStoreCfg.S3.ForcePathStyle)
+	assert.Equal(t, "key", objectStoreCfg.S3.AccessKeyID)
+	assert.Equal(t, "secret", objectStoreCfg.S3.SecretAccessKey)
+	assert.Equal(t, "from-jaeger-config", objectStoreCfg.KeyPrefix)
+	assert.Equal(t, 30*time.Second, objectStoreCfg.FlushInterval)
+	assert.Equal(t, true, objectStoreCfg.Gzip)
+	assert.Equal(t, 2*time.Second, objectStoreCfg.SpanWriter.BatchWindow)
+}
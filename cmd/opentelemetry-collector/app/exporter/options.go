@@ -0,0 +1,241 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-lib/metrics"
+	"go.uber.org/zap"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+)
+
+// Options holds optional settings of the span writer exporter.
+// The zero value stores spans exactly as the OTEL translator produces them.
+// Settings which do not hold runtime objects can be set in the collector configuration
+// of storage exporters, e.g. drop_internal_spans. Viper lowercases keys of maps read from the configuration.
+type Options struct {
+	// BaggageKeys lists span tag keys which are stored with the "baggage." prefix,
+	// preserving the OpenTracing representation of baggage items.
+	BaggageKeys []string `mapstructure:"baggage_keys"`
+	// SamplingProbabilityTraceStateKey is the trace state key holding the sampling probability of a span.
+	// If set, a valid probability is stored in the "sampler.probability" span tag.
+	SamplingProbabilityTraceStateKey string `mapstructure:"sampling_probability_trace_state_key"`
+	// SourceLocationTag enables combining code.filepath, code.lineno and code.function attributes
+	// into a "source.location" span tag formatted as "file:line:function".
+	// The original attributes are kept.
+	SourceLocationTag bool `mapstructure:"source_location_tag"`
+	// ErrorStackTag enables copying the exception.stacktrace attribute of the first exception event
+	// of a span into an "error.stack" span tag. The event is kept.
+	ErrorStackTag bool `mapstructure:"error_stack_tag"`
+	// MaxTagValueLength truncates values of tags promoted from span events, i.e. error.stack,
+	// to the given number of bytes. Zero disables truncation.
+	MaxTagValueLength int `mapstructure:"max_tag_value_length"`
+	// TagOrderTag enables storing the keys of span attributes in their original OTLP order
+	// in a comma separated "tag.order" span tag, as storage backends may reorder tags.
+	TagOrderTag bool `mapstructure:"tag_order_tag"`
+	// ServiceNameMap renames services, e.g. during service consolidation.
+	// Services missing from the map keep their name. Service names are matched case-insensitively,
+	// as viper lowercases the keys.
+	ServiceNameMap map[string]string `mapstructure:"service_name_map"`
+	// InstanceTag is the process tag key, e.g. "hostname" or "jaeger.instance", which receives
+	// the service.instance.id resource attribute to distinguish instances of a service.
+	// An existing tag with the key is kept. Empty disables the mapping.
+	InstanceTag string `mapstructure:"instance_tag"`
+	// CoalesceRepeatedLogs enables collapsing consecutive span logs with equal fields into the first log,
+	// with a "repeat" field holding the number of collapsed logs.
+	CoalesceRepeatedLogs bool `mapstructure:"coalesce_repeated_logs"`
+	// NormalizeDBStatement enables storing db.statement with literals replaced by "?"
+	// and collapsed whitespace in a "db.statement.normalized" span tag, for grouping of queries.
+	// The original attribute is kept.
+	NormalizeDBStatement bool `mapstructure:"normalize_db_statement"`
+	// MessagingPeerService enables deriving the "peer.service" span tag of messaging spans
+	// from messaging.system and messaging.destination attributes as "system/destination".
+	// An existing peer.service tag is kept.
+	MessagingPeerService bool `mapstructure:"messaging_peer_service"`
+	// RPCTags enables deriving tags of RPC spans from the rpc.service attribute: "peer.service"
+	// of spans other than server spans is set to the RPC service and "rpc.method" is taken from gRPC operation names "service/method"
+	// if the attribute is missing. Existing tags are kept, spans without rpc.service are unchanged.
+	RPCTags bool `mapstructure:"rpc_tags"`
+	// SanitizeNames enables replacing invalid UTF-8 in operation and service names, which breaks
+	// JSON serialization, by the Unicode replacement character. Sanitized names are counted in metrics.
+	SanitizeNames bool `mapstructure:"sanitize_names"`
+	// ComponentTagFromLibrary enables adding the legacy "component" tag, still used by some dashboards,
+	// with the name of the OTLP instrumentation library to spans without a "component" tag.
+	ComponentTagFromLibrary bool `mapstructure:"component_tag_from_library"`
+	// KindMismatchTag enables tagging spans whose kind disagrees with their references
+	// with "jaeger.kind_mismatch", e.g. client spans without a parent. The tag is purely diagnostic.
+	KindMismatchTag bool `mapstructure:"kind_mismatch_tag"`
+	// DepthTag enables storing the depth of spans in the trace tree in a "jaeger.depth" span tag,
+	// zero for root spans. The depth is computed from spans of the same push,
+	// spans with an ancestor missing from the push have depth -1.
+	DepthTag bool `mapstructure:"depth_tag"`
+	// ProbableRetryWindow enables tagging spans which look like retries with "jaeger.probable_retry",
+	// i.e. spans starting within the window after a span of the same trace, parent, operation
+	// and peer finished. Only spans of the same push are compared. The tag is purely diagnostic.
+	ProbableRetryWindow time.Duration `mapstructure:"probable_retry_window"`
+	// DeterministicIDs enables replacing empty or zero trace and span IDs, which are otherwise rejected,
+	// by IDs derived from the service name, operation name and start time of the span.
+	// Spans of one trace with a placeholder trace ID are assigned different trace IDs.
+	// It is meant for reproducible test pipelines only.
+	DeterministicIDs bool `mapstructure:"deterministic_ids"`
+	// ProcessTagKeys lists the identity keys kept in process tags, other process tags are removed.
+	// Empty keeps all process tags.
+	ProcessTagKeys []string `mapstructure:"process_tag_keys"`
+	// MoveTrimmedProcessTagsToSpans stores process tags removed by ProcessTagKeys as tags of each span
+	// of the process instead of discarding them.
+	MoveTrimmedProcessTagsToSpans bool `mapstructure:"move_trimmed_process_tags_to_spans"`
+	// MaxProcessTags limits the number of process tags, keeping the tags with the lowest keys
+	// and adding a "dropped_process_tags" tag with the number of removed tags. Zero disables the limit.
+	MaxProcessTags int `mapstructure:"max_process_tags"`
+
+	// MaxSpansPerTrace caps the number of spans stored per trace within TraceSpanCapWindow.
+	// Spans above the cap are dropped. Zero disables the cap.
+	MaxSpansPerTrace int `mapstructure:"max_spans_per_trace"`
+	// TraceSpanCapWindow is the period for which spans of a trace are counted,
+	// starting with the first span of the trace. Zero counts spans until the trace is evicted.
+	TraceSpanCapWindow time.Duration `mapstructure:"trace_span_cap_window"`
+	// TraceSpanCapCacheSize bounds the number of traces tracked by the span cap.
+	TraceSpanCapCacheSize int `mapstructure:"trace_span_cap_cache_size"`
+
+	// DropInternalSpans enables dropping spans of INTERNAL kind.
+	DropInternalSpans bool `mapstructure:"drop_internal_spans"`
+	// KeepInternalRootSpans exempts INTERNAL spans without a parent from DropInternalSpans,
+	// so that traces do not lose their root.
+	KeepInternalRootSpans bool `mapstructure:"keep_internal_root_spans"`
+
+	// DropBeyondRetention enables dropping spans which started longer than the given duration ago,
+	// which is meant to be the retention period of the storage. Zero disables the filter.
+	DropBeyondRetention time.Duration `mapstructure:"drop_beyond_retention"`
+
+	// RequiredProcessTags maps service names to process tag keys which processes of the service must have.
+	// Process tags are checked after conversion options, e.g. ProcessTagKeys, are applied.
+	// Service names are matched case-insensitively, as viper lowercases the keys.
+	RequiredProcessTags map[string][]string `mapstructure:"required_process_tags"`
+	// SchemaViolations controls whether spans of processes lacking a required tag are tagged or dropped.
+	SchemaViolations SchemaViolationMode `mapstructure:"schema_violations"`
+
+	// DropOrphanedSpans enables dropping spans whose parent was dropped by a filter,
+	// so that stored traces do not contain broken trees. Only parents dropped in the same push
+	// are considered, children arriving in later pushes are still stored.
+	DropOrphanedSpans bool `mapstructure:"drop_orphaned_spans"`
+
+	// MaxSpansPerSecond limits the rate of spans written by all pushes, to protect shared storage.
+	// SpansPerSecondBurst is the number of spans that can be written at once, at least one span.
+	// Zero defaults to MaxSpansPerSecond.
+	// Throttle controls whether spans exceeding the rate are shed or pushes are delayed.
+	// Zero disables the limit.
+	MaxSpansPerSecond   float64      `mapstructure:"max_spans_per_second"`
+	SpansPerSecondBurst float64      `mapstructure:"spans_per_second_burst"`
+	Throttle            ThrottleMode `mapstructure:"throttle"`
+
+	// PartialSpanWindow enables merging spans sent in parts, e.g. on start and on finish.
+	// Spans without end time are held for the window, until another part with the same trace
+	// and span ID arrives. Parts are combined by merging tags and logs and taking the timing
+	// of the finished part. Partial spans are written as they are when the window expires
+	// or on shutdown. Zero disables merging.
+	PartialSpanWindow time.Duration `mapstructure:"partial_span_window"`
+
+	// DuplicateSpans controls handling of spans with the same trace and span ID within one push,
+	// which some storage backends reject. Merged duplicates are not counted as dropped.
+	DuplicateSpans DuplicateSpanMode `mapstructure:"duplicate_spans"`
+
+	// BatchSequenceTag enables stamping spans of each push with a "jaeger.batch.seq" tag holding
+	// a sequence number increasing with each push. The sequence is per exporter and restarts from one.
+	BatchSequenceTag bool `mapstructure:"batch_sequence_tag"`
+
+	// BatchWindow enables accumulating spans across pushes and writing them every BatchWindow.
+	// Accumulated spans are also written on shutdown. Zero writes spans as soon as they are pushed.
+	BatchWindow time.Duration `mapstructure:"batch_window"`
+	// BatchWindowMaxSpans writes accumulated spans before BatchWindow elapses
+	// once the window holds this many spans. Zero disables the size threshold.
+	BatchWindowMaxSpans int `mapstructure:"batch_window_max_spans"`
+
+	// RecordTagValueLengths enables a histogram of lengths of string span tag values.
+	RecordTagValueLengths bool `mapstructure:"record_tag_value_lengths"`
+	// TagValueLengthKeys lists tag keys recorded under their own label,
+	// values of all other tags are recorded under the "other" label.
+	TagValueLengthKeys []string `mapstructure:"tag_value_length_keys"`
+
+	// VerifySpan enables reading back written spans for which it returns true,
+	// confirming that they were persisted. It requires the storage to provide a span reader.
+	// Verification issues a read per matching span, so the predicate should be selective.
+	VerifySpan func(span *model.Span) bool `mapstructure:"-"`
+
+	// RootSpanWriter receives the first root span of each trace in a push or batch window,
+	// for a lightweight store listing traces. Spans without a parent are roots.
+	// Root spans are written regardless of the outcome of the primary write, errors are logged.
+	RootSpanWriter spanstore.Writer `mapstructure:"-"`
+
+	// MirrorWriter receives spans of MirrorPercentage of traces in addition to the primary writer,
+	// e.g. to compare storage backends. Mirrored writes do not affect results of the primary write
+	// and are only reported in metrics.
+	MirrorWriter spanstore.Writer `mapstructure:"-"`
+	// MirrorPercentage is the percentage of traces, between 0 and 100, mirrored to MirrorWriter.
+	MirrorPercentage float64 `mapstructure:"mirror_percentage"`
+	// MirrorQueueSize bounds the number of spans waiting for MirrorWriter, spans beyond it are dropped.
+	// Zero defaults to 1000.
+	MirrorQueueSize int `mapstructure:"mirror_queue_size"`
+
+	// IndexTemplate routes spans to indices named by the template, e.g. "jaeger-span-{service}-{yyyy.MM.dd}".
+	// It supports the {service} placeholder and date placeholders of the span start time built from
+	// yyyy, MM, dd and HH. The span writer must implement IndexedWriter.
+	IndexTemplate string `mapstructure:"index_template"`
+
+	// ManifestWriter receives a manifest of spans stored by each write, i.e. each push
+	// or each batch window. Failures to write a manifest are logged.
+	ManifestWriter ManifestWriter `mapstructure:"-"`
+
+	// WriteErrorLogsPerSecond enables logging of write errors with the affected service and span count,
+	// limited to the given number of log entries per second. Zero disables the log.
+	WriteErrorLogsPerSecond float64 `mapstructure:"write_error_logs_per_second"`
+	// WriteTimeout bounds the time spent writing spans of one push or batch window.
+	// Spans remaining when the timeout elapses are not written and are counted as dropped
+	// with the context_cancelled reason. Zero disables the timeout.
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// SlowWriteThreshold enables logging of span writes and batch writes taking at least the threshold,
+	// with the affected services and span count. Zero disables the log.
+	SlowWriteThreshold time.Duration `mapstructure:"slow_write_threshold"`
+	// SlowWriteLogsPerSecond limits the number of slow write log entries per second, zero defaults to one.
+	SlowWriteLogsPerSecond float64 `mapstructure:"slow_write_logs_per_second"`
+
+	// DurableWrites makes pushes return only once their spans are persisted, so that receivers
+	// acknowledge spans to their source only after a successful write. With BatchWindow, pushes wait
+	// until their window is written and fail if the window fails. Writers implementing DurableWriter
+	// are flushed after each write, other writers are assumed to persist spans before WriteSpan returns,
+	// which does not hold for asynchronous writers like Kafka. It cannot be combined with PartialSpanWindow.
+	DurableWrites bool `mapstructure:"durable_writes"`
+
+	// LogShutdownSummary enables logging lifetime totals of written, failed, dropped and filtered spans
+	// on shutdown, after pending spans are flushed. Failed spans are spans whose write returned an error,
+	// dropped spans are spans which were not written for other reasons than filters, e.g. a timeout.
+	LogShutdownSummary bool `mapstructure:"log_shutdown_summary"`
+
+	// DebugTracer receives spans of internal enqueue, window flush and write operations of the exporter,
+	// revealing their latency. It should report to a different backend than the exporter. Nil disables tracing.
+	DebugTracer opentracing.Tracer `mapstructure:"-"`
+
+	// Logger is used to log errors, nothing is logged if nil.
+	Logger *zap.Logger `mapstructure:"-"`
+	// MetricsFactory is used to create exporter metrics, metrics are not reported if nil.
+	MetricsFactory metrics.Factory `mapstructure:"-"`
+	// ExpvarMetrics enables publishing exporter metrics as expvar variables, visible at /debug/vars,
+	// in addition to MetricsFactory. Variables are global, so metrics of all exporters with
+	// the option enabled are aggregated.
+	ExpvarMetrics bool `mapstructure:"expvar_metrics"`
+}
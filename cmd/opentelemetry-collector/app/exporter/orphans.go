@@ -0,0 +1,66 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/jaegertracing/jaeger/model"
+)
+
+type spanKey struct {
+	traceID model.TraceID
+	spanID  model.SpanID
+}
+
+// spanKeySet is a set of spans identified by trace and span ID.
+type spanKeySet map[spanKey]struct{}
+
+// add adds the span to the set, allocating the set if needed.
+func (set spanKeySet) add(span *model.Span) spanKeySet {
+	if set == nil {
+		set = make(spanKeySet)
+	}
+	set[spanKey{traceID: span.TraceID, spanID: span.SpanID}] = struct{}{}
+	return set
+}
+
+func (set spanKeySet) containsParentOf(span *model.Span) bool {
+	parentID := span.ParentSpanID()
+	if parentID == 0 {
+		return false
+	}
+	_, ok := set[spanKey{traceID: span.TraceID, spanID: parentID}]
+	return ok
+}
+
+// dropOrphans removes spans whose parent is in the dropped set, including descendants of such spans.
+// It returns the remaining spans and the number of removed spans.
+func dropOrphans(spans []*model.Span, dropped spanKeySet) ([]*model.Span, int) {
+	orphans := 0
+	for removed := true; removed; {
+		removed = false
+		kept := spans[:0]
+		for _, span := range spans {
+			if dropped.containsParentOf(span) {
+				dropped.add(span)
+				orphans++
+				removed = true
+				continue
+			}
+			kept = append(kept, span)
+		}
+		spans = kept
+	}
+	return spans, orphans
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"testing"
+
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics/metricstest"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+func childSpan(traceID model.TraceID, spanID, parentID model.SpanID) *model.Span {
+	return &model.Span{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		References: model.MaybeAddParentSpanID(traceID, parentID, nil),
+	}
+}
+
+func TestDropOrphans(t *testing.T) {
+	traceID := model.NewTraceID(1, 1)
+	root := childSpan(traceID, 1, 0)
+	child := childSpan(traceID, 3, 2)
+	grandChild := childSpan(traceID, 4, 3)
+	otherTraceChild := childSpan(model.NewTraceID(1, 2), 5, 2)
+
+	dropped := spanKeySet{}.add(childSpan(traceID, 2, 1))
+	// grand child precedes its parent to verify that descendants are dropped regardless of order
+	kept, orphans := dropOrphans([]*model.Span{root, grandChild, child, otherTraceChild}, dropped)
+	assert.Equal(t, 2, orphans)
+	assert.Equal(t, []*model.Span{root, otherTraceChild}, kept)
+}
+
+func TestDropOrphans_intactTree(t *testing.T) {
+	traceID := model.NewTraceID(1, 1)
+	spans := []*model.Span{childSpan(traceID, 1, 0), childSpan(traceID, 2, 1), childSpan(traceID, 3, 2)}
+	dropped := spanKeySet{}.add(childSpan(traceID, 7, 0))
+	kept, orphans := dropOrphans(append([]*model.Span(nil), spans...), dropped)
+	assert.Equal(t, 0, orphans)
+	assert.Equal(t, spans, kept)
+}
+
+func TestStore_dropOrphanedSpans(t *testing.T) {
+	span := func(id, parentID string) *tracev1.Span {
+		return &tracev1.Span{TraceId: testTraceID, SpanId: []byte(id), ParentSpanId: []byte(parentID)}
+	}
+	td := tracesWithSpans(
+		span("0000000A", ""),
+		span("0000000C", "0000000B"),
+		// B and D exceed the span cap
+		span("0000000B", "0000000A"),
+		span("0000000D", "0000000C"),
+	)
+	tests := []struct {
+		caption     string
+		dropOrphans bool
+		written     int
+		orphans     int
+	}{
+		{caption: "orphans are dropped", dropOrphans: true, written: 1, orphans: 1},
+		{caption: "orphans are kept by default", written: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			writer := &recordingWriter{}
+			metricsFactory := metricstest.NewFactory(0)
+			s := newStorage(writer, Options{MaxSpansPerTrace: 2, DropOrphanedSpans: test.dropOrphans, MetricsFactory: metricsFactory})
+			dropped, err := s.traceDataPusher(context.Background(), td)
+			require.NoError(t, err)
+			assert.Equal(t, 2+test.orphans, dropped)
+			assert.Len(t, writer.written(), test.written)
+			metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{
+				Name:  "jaeger_exporter.spans.dropped",
+				Tags:  map[string]string{"reason": "parent_dropped"},
+				Value: test.orphans,
+			})
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/uber/jaeger-lib/metrics"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+// partialSpanMerger holds partial spans, i.e. spans sent before they finished,
+// until another part of the span arrives or the window of the partial span expires.
+type partialSpanMerger struct {
+	window  time.Duration
+	flush   func(spans []*model.Span)
+	timeNow func() time.Time
+
+	mux     sync.Mutex
+	pending map[spanKey]*pendingSpan
+	// pendingGauge reports the number of pending partial spans
+	pendingGauge metrics.Gauge
+
+	stop      chan struct{}
+	stopped   sync.WaitGroup
+	closeOnce sync.Once
+}
+
+type pendingSpan struct {
+	span    *model.Span
+	expires time.Time
+}
+
+func newPartialSpanMerger(window time.Duration, flush func(spans []*model.Span), pending metrics.Gauge, timeNow func() time.Time) *partialSpanMerger {
+	return &partialSpanMerger{
+		window:       window,
+		flush:        flush,
+		timeNow:      timeNow,
+		pending:      make(map[spanKey]*pendingSpan),
+		pendingGauge: pending,
+		stop:         make(chan struct{}),
+	}
+}
+
+// start periodically flushes partial spans whose window expired.
+func (m *partialSpanMerger) start() {
+	ticker := time.NewTicker(m.window)
+	m.stopped.Add(1)
+	go func() {
+		defer m.stopped.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.flushExpired()
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// merge combines spans with their pending parts and returns spans which are ready to be written.
+// Partial spans without a pending part are held.
+func (m *partialSpanMerger) merge(spans []*model.Span, partial partialSpanSet) []*model.Span {
+	now := m.timeNow()
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	ready := spans[:0]
+	for _, span := range spans {
+		key := spanKey{traceID: span.TraceID, spanID: span.SpanID}
+		isPartial := partial.contains(span)
+		if p, ok := m.pending[key]; ok {
+			mergeSpanParts(p.span, span, isPartial)
+			if isPartial {
+				continue
+			}
+			delete(m.pending, key)
+			ready = append(ready, p.span)
+			continue
+		}
+		if isPartial {
+			m.pending[key] = &pendingSpan{span: span, expires: now.Add(m.window)}
+			continue
+		}
+		ready = append(ready, span)
+	}
+	m.pendingGauge.Update(int64(len(m.pending)))
+	return ready
+}
+
+// mergeSpanParts adds tags and logs of part to span and takes the timing of part if it is complete.
+func mergeSpanParts(span, part *model.Span, partIsPartial bool) {
+	mergeTags(span, part.Tags)
+	span.Logs = append(span.Logs, part.Logs...)
+	if span.OperationName == "" {
+		span.OperationName = part.OperationName
+	}
+	if !partIsPartial {
+		span.StartTime = part.StartTime
+		span.Duration = part.Duration
+	}
+}
+
+// partialSpanSet holds translated spans which were sent before they finished.
+type partialSpanSet map[*model.Span]struct{}
+
+func (s partialSpanSet) add(span *model.Span) partialSpanSet {
+	if s == nil {
+		s = make(partialSpanSet)
+	}
+	s[span] = struct{}{}
+	return s
+}
+
+func (s partialSpanSet) contains(span *model.Span) bool {
+	_, ok := s[span]
+	return ok
+}
+
+// flushExpired writes partial spans whose window expired as they are.
+func (m *partialSpanMerger) flushExpired() {
+	now := m.timeNow()
+	m.mux.Lock()
+	var expired []*model.Span
+	for key, p := range m.pending {
+		if !now.Before(p.expires) {
+			expired = append(expired, p.span)
+			delete(m.pending, key)
+		}
+	}
+	m.pendingGauge.Update(int64(len(m.pending)))
+	m.mux.Unlock()
+	m.flushSpans(expired)
+}
+
+// close stops the periodic flush and writes all pending partial spans.
+func (m *partialSpanMerger) close() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		m.stopped.Wait()
+		m.mux.Lock()
+		pending := make([]*model.Span, 0, len(m.pending))
+		for key, p := range m.pending {
+			pending = append(pending, p.span)
+			delete(m.pending, key)
+		}
+		m.pendingGauge.Update(0)
+		m.mux.Unlock()
+		m.flushSpans(pending)
+	})
+}
+
+func (m *partialSpanMerger) flushSpans(spans []*model.Span) {
+	if len(spans) > 0 {
+		m.flush(spans)
+	}
+}
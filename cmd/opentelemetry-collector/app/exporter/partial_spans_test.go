@@ -0,0 +1,194 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	commonv1 "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics"
+	"github.com/uber/jaeger-lib/metrics/metricstest"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+func newPartialSpanStorage(window time.Duration, clock *fakeClock, opts Options) (*storage, *recordingWriter) {
+	writer := &recordingWriter{}
+	s := newStorage(writer, opts)
+	s.partials = newPartialSpanMerger(window, s.flushPartialSpans, s.metrics.SpansPending[pendingQueuePartialSpans], clock.timeNow)
+	return s, writer
+}
+
+func TestStore_mergesPartialSpans(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s, writer := newPartialSpanStorage(time.Minute, clock, Options{})
+
+	start := &tracev1.Span{
+		TraceId:           testTraceID,
+		SpanId:            testSpanID,
+		Name:              "op",
+		StartTimeUnixNano: 1000,
+		Attributes:        []*commonv1.AttributeKeyValue{stringAttr("a", "1")},
+		Events:            []*tracev1.Span_Event{{TimeUnixNano: 1500, Attributes: []*commonv1.AttributeKeyValue{stringAttr("event", "started")}}},
+	}
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpans(start))
+	require.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	assert.Empty(t, writer.written())
+
+	finish := &tracev1.Span{
+		TraceId:           testTraceID,
+		SpanId:            testSpanID,
+		Name:              "op",
+		StartTimeUnixNano: 1000,
+		EndTimeUnixNano:   3000,
+		Attributes:        []*commonv1.AttributeKeyValue{stringAttr("a", "2"), stringAttr("b", "3")},
+		Events:            []*tracev1.Span_Event{{TimeUnixNano: 2500, Attributes: []*commonv1.AttributeKeyValue{stringAttr("event", "finished")}}},
+	}
+	dropped, err = s.traceDataPusher(context.Background(), tracesWithSpans(finish))
+	require.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	written := writer.written()
+	require.Len(t, written, 1)
+	assert.Equal(t, 2*time.Microsecond, written[0].Duration)
+	assert.Equal(t, []model.KeyValue{model.String("a", "1"), model.String("b", "3")}, written[0].Tags)
+	assert.Len(t, written[0].Logs, 2)
+	assert.Empty(t, s.partials.pending)
+}
+
+func TestStore_flushesExpiredPartialSpans(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s, writer := newPartialSpanStorage(time.Minute, clock, Options{})
+
+	pushSpans(t, s, "00000001")
+	clock.advance(30 * time.Second)
+	pushSpans(t, s, "00000002")
+	s.partials.flushExpired()
+	assert.Empty(t, writer.written())
+
+	clock.advance(30 * time.Second)
+	s.partials.flushExpired()
+	written := writer.written()
+	require.Len(t, written, 1)
+	assert.Equal(t, model.SpanID(0x3030303030303031), written[0].SpanID)
+	assert.Equal(t, time.Duration(0), written[0].Duration, "partial span is written as it is")
+
+	require.NoError(t, s.shutdown(context.Background()))
+	assert.Len(t, writer.written(), 2)
+}
+
+func TestStore_partialSpansPendingGauge(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	metricsFactory := metricstest.NewFactory(0)
+	s, _ := newPartialSpanStorage(time.Minute, clock, Options{MetricsFactory: metricsFactory})
+	pending := func(value int) metricstest.ExpectedMetric {
+		return metricstest.ExpectedMetric{Name: "jaeger_exporter.spans.pending", Tags: map[string]string{"queue": "partial_spans"}, Value: value}
+	}
+
+	pushSpans(t, s, "00000001", "00000002")
+	metricsFactory.AssertGaugeMetrics(t, pending(2))
+	clock.advance(30 * time.Second)
+	pushSpans(t, s, "00000003")
+	clock.advance(30 * time.Second)
+	s.partials.flushExpired()
+	metricsFactory.AssertGaugeMetrics(t, pending(1))
+	require.NoError(t, s.shutdown(context.Background()))
+	metricsFactory.AssertGaugeMetrics(t, pending(0))
+}
+
+func TestStore_writesCompleteSpansWithoutPendingPart(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s, writer := newPartialSpanStorage(time.Minute, clock, Options{})
+
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{
+		TraceId:           testTraceID,
+		SpanId:            testSpanID,
+		StartTimeUnixNano: 1000,
+		EndTimeUnixNano:   2000,
+	}))
+	require.NoError(t, err)
+	assert.Len(t, writer.written(), 1)
+}
+
+func TestStore_writesZeroDurationSpans(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s, writer := newPartialSpanStorage(time.Minute, clock, Options{})
+
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{
+		TraceId:           testTraceID,
+		SpanId:            testSpanID,
+		StartTimeUnixNano: 1000,
+		EndTimeUnixNano:   1000,
+	}))
+	require.NoError(t, err)
+	written := writer.written()
+	require.Len(t, written, 1, "spans which finished when they started are not partial")
+	assert.Equal(t, time.Duration(0), written[0].Duration)
+	assert.Empty(t, s.partials.pending)
+}
+
+func TestStore_expiredPartialSpansAreProcessedLikePushedSpans(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s, writer := newPartialSpanStorage(time.Minute, clock, Options{BatchSequenceTag: true})
+
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000001"), StartTimeUnixNano: 1000},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000002"), StartTimeUnixNano: 1000, EndTimeUnixNano: 2000},
+	))
+	require.NoError(t, err)
+	require.Len(t, writer.written(), 1)
+
+	clock.advance(time.Minute)
+	s.partials.flushExpired()
+	written := writer.written()
+	require.Len(t, written, 2)
+	assert.Equal(t, []model.KeyValue{model.Int64(batchSequenceTag, 1)}, written[0].Tags)
+	assert.Equal(t, []model.KeyValue{model.Int64(batchSequenceTag, 2)}, written[1].Tags, "expired partial spans get a batch sequence")
+}
+
+func TestStore_expiredPartialSpansAreBatched(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s, writer := newPartialSpanStorage(time.Minute, clock, Options{})
+	s.batcher = newWindowBatcher(time.Hour, 0, s.flushWindow, s.metrics.SpansPending[pendingQueueBatchWindow], clock.timeNow)
+
+	pushSpans(t, s, "00000001")
+	clock.advance(time.Minute)
+	s.partials.flushExpired()
+	assert.Empty(t, writer.written(), "expired partial spans are added to the batch window")
+
+	require.NoError(t, s.shutdown(context.Background()))
+	assert.Len(t, writer.written(), 1)
+}
+
+func TestPartialSpanMerger_start(t *testing.T) {
+	flushed := make(chan []*model.Span, 1)
+	m := newPartialSpanMerger(time.Millisecond, func(spans []*model.Span) { flushed <- spans }, metrics.NullGauge, time.Now)
+	m.start()
+	defer m.close()
+	span := &model.Span{SpanID: 1}
+	ready := m.merge([]*model.Span{span}, partialSpanSet{}.add(span))
+	assert.Empty(t, ready)
+	select {
+	case spans := <-flushed:
+		assert.Len(t, spans, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("partial span was not flushed")
+	}
+}
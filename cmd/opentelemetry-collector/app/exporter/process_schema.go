@@ -0,0 +1,61 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"strings"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+const schemaViolationTag = "jaeger.schema_violation"
+
+// SchemaViolationMode controls handling of spans whose process lacks a required process tag.
+type SchemaViolationMode string
+
+const (
+	// SchemaViolationTag writes violating spans with a "jaeger.schema_violation" tag.
+	SchemaViolationTag SchemaViolationMode = ""
+	// SchemaViolationDrop drops violating spans.
+	SchemaViolationDrop SchemaViolationMode = "drop"
+)
+
+// processSchema checks processes for process tags required by their service.
+// Service names are matched case-insensitively, as viper lowercases the keys of the required tags.
+type processSchema struct {
+	required map[string][]string
+	drop     bool
+}
+
+func newProcessSchema(required map[string][]string, mode SchemaViolationMode) *processSchema {
+	lowercased := make(map[string][]string, len(required))
+	for service, keys := range required {
+		lowercased[strings.ToLower(service)] = keys
+	}
+	return &processSchema{required: lowercased, drop: mode == SchemaViolationDrop}
+}
+
+// violated returns true if the process lacks a tag required by its service.
+func (s *processSchema) violated(process *model.Process) bool {
+	if process == nil {
+		return false
+	}
+	for _, key := range s.required[strings.ToLower(process.ServiceName)] {
+		if _, ok := model.KeyValues(process.Tags).FindByKey(key); !ok {
+			return true
+		}
+	}
+	return false
+}
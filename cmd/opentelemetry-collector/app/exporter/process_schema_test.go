@@ -0,0 +1,108 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"testing"
+
+	commonv1 "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	resourcev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics/metricstest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+func TestStore_requiredProcessTags(t *testing.T) {
+	resourceSpans := func(attrs ...*commonv1.AttributeKeyValue) *tracev1.ResourceSpans {
+		return &tracev1.ResourceSpans{
+			Resource: &resourcev1.Resource{Attributes: attrs},
+			InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: []*tracev1.Span{
+				{TraceId: testTraceID, SpanId: testSpanID},
+			}}},
+		}
+	}
+	td := pdata.TracesFromOtlp([]*tracev1.ResourceSpans{
+		resourceSpans(stringAttr("service.name", "frontend"), stringAttr("team", "web"), stringAttr("env", "prod")),
+		resourceSpans(stringAttr("service.name", "frontend"), stringAttr("team", "web")),
+		resourceSpans(stringAttr("service.name", "backend")),
+	})
+	required := map[string][]string{"frontend": {"team", "env"}}
+	tests := []struct {
+		caption    string
+		mode       SchemaViolationMode
+		written    []string
+		violations []bool
+		dropped    int
+	}{
+		{
+			caption:    "tagged",
+			written:    []string{"frontend", "frontend", "backend"},
+			violations: []bool{false, true, false},
+		},
+		{
+			caption:    "dropped",
+			mode:       SchemaViolationDrop,
+			written:    []string{"frontend", "backend"},
+			violations: []bool{false, false},
+			dropped:    1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			writer := &recordingWriter{}
+			metricsFactory := metricstest.NewFactory(0)
+			s := newStorage(writer, Options{RequiredProcessTags: required, SchemaViolations: test.mode, MetricsFactory: metricsFactory})
+			dropped, err := s.traceDataPusher(context.Background(), td)
+			require.NoError(t, err)
+			assert.Equal(t, test.dropped, dropped)
+			var services []string
+			var violations []bool
+			for _, span := range writer.written() {
+				services = append(services, span.Process.ServiceName)
+				_, violation := model.KeyValues(span.Tags).FindByKey("jaeger.schema_violation")
+				violations = append(violations, violation)
+			}
+			assert.Equal(t, test.written, services)
+			assert.Equal(t, test.violations, violations)
+			metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{
+				Name:  "jaeger_exporter.spans.dropped",
+				Tags:  map[string]string{"reason": "schema_violation"},
+				Value: test.dropped,
+			})
+		})
+	}
+}
+
+func TestStore_requiredProcessTagsFromConfig(t *testing.T) {
+	writer := &recordingWriter{}
+	opts := loadOptions(t)
+	opts.MetricsFactory = metricstest.NewFactory(0)
+	s := newStorage(writer, opts)
+	_, err := s.traceDataPusher(context.Background(), pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+		Resource: &resourcev1.Resource{Attributes: []*commonv1.AttributeKeyValue{stringAttr("service.name", "FrontEnd"), stringAttr("team", "web")}},
+		InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: []*tracev1.Span{
+			{TraceId: testTraceID, SpanId: testSpanID},
+		}}},
+	}}))
+	require.NoError(t, err)
+	require.Len(t, writer.written(), 1)
+	_, violation := model.KeyValues(writer.written()[0].Tags).FindByKey("jaeger.schema_violation")
+	assert.True(t, violation)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket holding up to maxBalance credits,
+// replenished at creditsPerSecond.
+type rateLimiter struct {
+	mux              sync.Mutex
+	creditsPerSecond float64
+	maxBalance       float64
+	balance          float64
+	lastTick         time.Time
+	timeNow          func() time.Time
+}
+
+func newRateLimiter(creditsPerSecond, maxBalance float64, timeNow func() time.Time) *rateLimiter {
+	return &rateLimiter{
+		creditsPerSecond: creditsPerSecond,
+		maxBalance:       maxBalance,
+		balance:          maxBalance,
+		lastTick:         timeNow(),
+		timeNow:          timeNow,
+	}
+}
+
+// checkCredit takes cost credits from the balance if available.
+func (rl *rateLimiter) checkCredit(cost float64) bool {
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+	rl.updateBalance()
+	if rl.balance >= cost {
+		rl.balance -= cost
+		return true
+	}
+	return false
+}
+
+// takeCredits takes up to cost whole credits from the balance and returns the number of credits taken.
+func (rl *rateLimiter) takeCredits(cost int) int {
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+	rl.updateBalance()
+	taken := int(rl.balance)
+	if taken > cost {
+		taken = cost
+	}
+	rl.balance -= float64(taken)
+	return taken
+}
+
+// timeUntilCredit returns the time until the balance holds a whole credit.
+func (rl *rateLimiter) timeUntilCredit() time.Duration {
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+	rl.updateBalance()
+	if rl.balance >= 1 {
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - rl.balance) / rl.creditsPerSecond * float64(time.Second)))
+}
+
+// updateBalance must be called with the mutex held.
+func (rl *rateLimiter) updateBalance() {
+	now := rl.timeNow()
+	rl.balance += now.Sub(rl.lastTick).Seconds() * rl.creditsPerSecond
+	rl.lastTick = now
+	if rl.balance > rl.maxBalance {
+		rl.balance = rl.maxBalance
+	}
+}
+
+// rateLimitedLogger drops log entries exceeding the configured rate.
+type rateLimitedLogger struct {
+	limiter *rateLimiter
+}
+
+// newRateLimitedLogger allows logsPerSecond entries, with bursts of at least one entry.
+func newRateLimitedLogger(logsPerSecond float64, timeNow func() time.Time) *rateLimitedLogger {
+	burst := logsPerSecond
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitedLogger{limiter: newRateLimiter(logsPerSecond, burst, timeNow)}
+}
+
+// allow returns true if an entry can be logged.
+func (l *rateLimitedLogger) allow() bool {
+	return l != nil && l.limiter.checkCredit(1)
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	commonv1 "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	resourcev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRateLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := newRateLimiter(2, 4, clock.timeNow)
+	for i := 0; i < 4; i++ {
+		assert.True(t, limiter.checkCredit(1))
+	}
+	assert.False(t, limiter.checkCredit(1))
+
+	clock.advance(500 * time.Millisecond)
+	assert.True(t, limiter.checkCredit(1))
+	assert.False(t, limiter.checkCredit(1))
+
+	clock.advance(time.Hour)
+	assert.True(t, limiter.checkCredit(4), "balance is capped")
+	assert.False(t, limiter.checkCredit(1))
+}
+
+func TestStore_writeErrorLogIsRateLimited(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	core, logs := observer.New(zap.ErrorLevel)
+	s := newStorage(spanWriter{err: errors.New("could not store")}, Options{Logger: zap.New(core)})
+	s.writeErrorLog = newRateLimitedLogger(2, clock.timeNow)
+	td := pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+		Resource: &resourcev1.Resource{Attributes: []*commonv1.AttributeKeyValue{stringAttr("service.name", "frontend")}},
+		InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: []*tracev1.Span{
+			{TraceId: testTraceID, SpanId: testSpanID, Name: "error"},
+			{TraceId: testTraceID, SpanId: testSpanID, Name: "error"},
+			{TraceId: testTraceID, SpanId: testSpanID},
+		}}},
+	}})
+
+	for i := 0; i < 10; i++ {
+		dropped, err := s.traceDataPusher(context.Background(), td)
+		assert.Error(t, err)
+		assert.Equal(t, 2, dropped)
+	}
+	assert.Equal(t, 2, logs.Len())
+	entry := logs.All()[0]
+	assert.Equal(t, "Failed to write spans", entry.Message)
+	assert.Equal(t, map[string]interface{}{
+		"service": "frontend",
+		"spans":   int64(2),
+		"error":   "could not store",
+	}, entry.ContextMap())
+
+	clock.advance(time.Second)
+	s.traceDataPusher(context.Background(), td)
+	s.traceDataPusher(context.Background(), td)
+	s.traceDataPusher(context.Background(), td)
+	assert.Equal(t, 4, logs.Len())
+}
+
+func TestStore_writeErrorLogDisabled(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	s := newStorage(spanWriter{err: errors.New("could not store")}, Options{Logger: zap.New(core)})
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Name: "error"}))
+	assert.Error(t, err)
+	assert.Equal(t, 0, logs.Len())
+}
+
+func TestRateLimiter_takeCredits(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := newRateLimiter(2, 4, clock.timeNow)
+	assert.Equal(t, 3, limiter.takeCredits(3))
+	assert.Equal(t, 1, limiter.takeCredits(3))
+	assert.Equal(t, 0, limiter.takeCredits(3))
+	assert.Equal(t, 500*time.Millisecond, limiter.timeUntilCredit())
+
+	clock.advance(250 * time.Millisecond)
+	assert.Equal(t, 250*time.Millisecond, limiter.timeUntilCredit())
+	clock.advance(250 * time.Millisecond)
+	assert.Equal(t, time.Duration(0), limiter.timeUntilCredit())
+	assert.Equal(t, 1, limiter.takeCredits(3))
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+const probableRetryTag = "jaeger.probable_retry"
+
+// peerTagKeys identify the peer of a span, in order of preference.
+var peerTagKeys = []string{peerServiceTag, "net.peer.name", "net.peer.ip"}
+
+// retryKey groups spans which may be attempts of the same call.
+type retryKey struct {
+	traceID   model.TraceID
+	parentID  model.SpanID
+	operation string
+	peer      string
+}
+
+// addProbableRetryTags tags spans starting within the window after a span of the same trace, parent,
+// operation and peer finished. Only spans of the batches are considered, the heuristic is meant for
+// diagnostics only.
+func addProbableRetryTags(batches []*model.Batch, window time.Duration) {
+	groups := make(map[retryKey][]*model.Span)
+	for _, batch := range batches {
+		for _, span := range batch.Spans {
+			key := retryKey{
+				traceID:   span.TraceID,
+				parentID:  span.ParentSpanID(),
+				operation: span.OperationName,
+				peer:      spanPeer(span),
+			}
+			groups[key] = append(groups[key], span)
+		}
+	}
+	for _, spans := range groups {
+		if len(spans) < 2 {
+			continue
+		}
+		sort.SliceStable(spans, func(i, j int) bool {
+			return spans[i].StartTime.Before(spans[j].StartTime)
+		})
+		for i := 1; i < len(spans); i++ {
+			gap := spans[i].StartTime.Sub(spans[i-1].StartTime.Add(spans[i-1].Duration))
+			if gap >= 0 && gap <= window {
+				spans[i].Tags = append(spans[i].Tags, model.Bool(probableRetryTag, true))
+			}
+		}
+	}
+}
+
+func spanPeer(span *model.Span) string {
+	for _, key := range peerTagKeys {
+		if tag, ok := model.KeyValues(span.Tags).FindByKey(key); ok {
+			return tag.AsString()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+func TestAddProbableRetryTags(t *testing.T) {
+	start := time.Unix(100, 0)
+	parent := []model.SpanRef{model.NewChildOfRef(model.NewTraceID(0, 1), 1)}
+	peer := []model.KeyValue{model.String("peer.service", "db")}
+	call := func(spanID model.SpanID, operation string, offset, duration time.Duration, tags []model.KeyValue) *model.Span {
+		return &model.Span{
+			TraceID:       model.NewTraceID(0, 1),
+			SpanID:        spanID,
+			OperationName: operation,
+			References:    parent,
+			StartTime:     start.Add(offset),
+			Duration:      duration,
+			Tags:          append([]model.KeyValue(nil), tags...),
+		}
+	}
+	tests := []struct {
+		caption string
+		spans   []*model.Span
+		retries []model.SpanID
+	}{
+		{
+			caption: "retries",
+			spans: []*model.Span{
+				call(4, "query", 220*time.Millisecond, 100*time.Millisecond, peer),
+				call(2, "query", 0, 100*time.Millisecond, peer),
+				call(3, "query", 110*time.Millisecond, 100*time.Millisecond, peer),
+			},
+			retries: []model.SpanID{3, 4},
+		},
+		{
+			caption: "normal sequence",
+			spans: []*model.Span{
+				call(2, "get", 0, 100*time.Millisecond, peer),
+				call(3, "query", 110*time.Millisecond, 100*time.Millisecond, peer),
+				call(4, "query", 220*time.Millisecond, 100*time.Millisecond, []model.KeyValue{model.String("peer.service", "cache")}),
+				call(5, "query", 5*time.Second, 100*time.Millisecond, peer),
+			},
+		},
+		{
+			caption: "concurrent calls",
+			spans: []*model.Span{
+				call(2, "query", 0, 100*time.Millisecond, peer),
+				call(3, "query", 10*time.Millisecond, 100*time.Millisecond, peer),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			addProbableRetryTags([]*model.Batch{{Spans: test.spans}}, time.Second)
+			var retries []model.SpanID
+			for _, span := range test.spans {
+				if tag, ok := model.KeyValues(span.Tags).FindByKey(probableRetryTag); ok {
+					assert.True(t, tag.Bool())
+					retries = append(retries, span.SpanID)
+				}
+			}
+			assert.ElementsMatch(t, test.retries, retries)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/jaegertracing/jaeger/model"
+)
+
+// rootSpans returns the first span without a parent of each trace.
+func rootSpans(spans []*model.Span) []*model.Span {
+	var roots []*model.Span
+	seen := make(map[model.TraceID]bool)
+	for _, span := range spans {
+		if span.ParentSpanID() != 0 || seen[span.TraceID] {
+			continue
+		}
+		seen[span.TraceID] = true
+		roots = append(roots, span)
+	}
+	return roots
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"testing"
+
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+func TestRootSpans(t *testing.T) {
+	traceID := model.NewTraceID(1, 1)
+	root := childSpan(traceID, 1, 0)
+	secondRoot := childSpan(traceID, 5, 0)
+	linked := &model.Span{
+		TraceID:    model.NewTraceID(1, 2),
+		SpanID:     3,
+		References: []model.SpanRef{model.NewFollowsFromRef(traceID, 1)},
+	}
+	orphanTrace := childSpan(model.NewTraceID(1, 3), 4, 2)
+	spans := []*model.Span{childSpan(traceID, 2, 1), root, linked, orphanTrace, secondRoot}
+	assert.Equal(t, []*model.Span{root, linked}, rootSpans(spans))
+}
+
+func TestStore_rootSpanWriter(t *testing.T) {
+	writer, roots := &recordingWriter{}, &recordingWriter{}
+	s := newStorage(writer, Options{RootSpanWriter: roots})
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("0000000B"), ParentSpanId: []byte("0000000A")},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("0000000A")},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("0000000C"), ParentSpanId: []byte("0000000B")},
+	))
+	require.NoError(t, err)
+	assert.Len(t, writer.written(), 3)
+	written := roots.written()
+	require.Len(t, written, 1)
+	assert.Equal(t, model.SpanID(0x3030303030303041), written[0].SpanID)
+
+	_, err = s.traceDataPusher(context.Background(), tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("0000000D"), ParentSpanId: []byte("0000000A")},
+	))
+	require.NoError(t, err)
+	assert.Len(t, roots.written(), 1, "pushes without the root write no root span")
+}
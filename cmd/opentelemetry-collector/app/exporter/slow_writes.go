@@ -0,0 +1,87 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+// slowWriteDetector logs span writes and batch writes exceeding the latency threshold.
+type slowWriteDetector struct {
+	threshold time.Duration
+	logger    *zap.Logger
+	log       *rateLimitedLogger
+	timeNow   func() time.Time
+}
+
+func newSlowWriteDetector(threshold time.Duration, logsPerSecond float64, logger *zap.Logger, timeNow func() time.Time) *slowWriteDetector {
+	if logsPerSecond <= 0 {
+		logsPerSecond = 1
+	}
+	return &slowWriteDetector{
+		threshold: threshold,
+		logger:    logger,
+		log:       newRateLimitedLogger(logsPerSecond, timeNow),
+		timeNow:   timeNow,
+	}
+}
+
+// observeSpan logs the write of span started at start if it was slow.
+func (d *slowWriteDetector) observeSpan(span *model.Span, start time.Time) {
+	latency := d.timeNow().Sub(start)
+	if latency < d.threshold || !d.log.allow() {
+		return
+	}
+	service := ""
+	if span.Process != nil {
+		service = span.Process.ServiceName
+	}
+	d.logger.Warn("Slow span write",
+		zap.String("service", service),
+		zap.Stringer("trace_id", span.TraceID),
+		zap.Duration("latency", latency),
+		zap.Duration("threshold", d.threshold))
+}
+
+// observeBatch logs the write of spans started at start if it was slow.
+func (d *slowWriteDetector) observeBatch(spans []*model.Span, start time.Time) {
+	latency := d.timeNow().Sub(start)
+	if latency < d.threshold || !d.log.allow() {
+		return
+	}
+	d.logger.Warn("Slow batch write",
+		zap.Strings("services", batchServices(spans)),
+		zap.Int("spans", len(spans)),
+		zap.Duration("latency", latency),
+		zap.Duration("threshold", d.threshold))
+}
+
+// batchServices returns distinct service names of spans in order of appearance.
+func batchServices(spans []*model.Span) []string {
+	var services []string
+	seen := make(map[string]bool)
+	for _, span := range spans {
+		if span.Process == nil || seen[span.Process.ServiceName] {
+			continue
+		}
+		seen[span.Process.ServiceName] = true
+		services = append(services, span.Process.ServiceName)
+	}
+	return services
+}
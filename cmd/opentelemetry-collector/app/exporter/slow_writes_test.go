@@ -0,0 +1,101 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	commonv1 "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	resourcev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/resource/v1"
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+// slowWriter takes delay of the fake clock to write spans named "slow".
+type slowWriter struct {
+	clock *fakeClock
+	delay time.Duration
+}
+
+func (w slowWriter) WriteSpan(span *model.Span) error {
+	if span.OperationName == "slow" {
+		w.clock.advance(w.delay)
+	}
+	return nil
+}
+
+func TestStore_slowWrites(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	core, logs := observer.New(zap.WarnLevel)
+	s := newStorage(slowWriter{clock: clock, delay: 300 * time.Millisecond}, Options{Logger: zap.New(core)})
+	s.slowWrites = newSlowWriteDetector(time.Second, 10, s.logger, clock.timeNow)
+	td := pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+		Resource: &resourcev1.Resource{Attributes: []*commonv1.AttributeKeyValue{stringAttr("service.name", "frontend")}},
+		InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: []*tracev1.Span{
+			{TraceId: testTraceID, SpanId: testSpanID, Name: "slow"},
+			{TraceId: testTraceID, SpanId: testSpanID},
+		}}},
+	}})
+
+	_, err := s.traceDataPusher(context.Background(), td)
+	require.NoError(t, err)
+	assert.Equal(t, 0, logs.Len(), "writes below the threshold are not logged")
+
+	s.slowWrites.threshold = 300 * time.Millisecond
+	_, err = s.traceDataPusher(context.Background(), td)
+	require.NoError(t, err)
+	require.Equal(t, 2, logs.Len())
+	spanEntry, batchEntry := logs.All()[0], logs.All()[1]
+	assert.Equal(t, "Slow span write", spanEntry.Message)
+	assert.Equal(t, map[string]interface{}{
+		"service":   "frontend",
+		"trace_id":  model.NewTraceID(0x3031323334353637, 0x3839616263646566).String(),
+		"latency":   300 * time.Millisecond,
+		"threshold": 300 * time.Millisecond,
+	}, spanEntry.ContextMap())
+	assert.Equal(t, "Slow batch write", batchEntry.Message)
+	assert.Equal(t, map[string]interface{}{
+		"services":  []interface{}{"frontend"},
+		"spans":     int64(2),
+		"latency":   300 * time.Millisecond,
+		"threshold": 300 * time.Millisecond,
+	}, batchEntry.ContextMap())
+}
+
+func TestSlowWriteDetector_rateLimited(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	core, logs := observer.New(zap.WarnLevel)
+	d := newSlowWriteDetector(time.Millisecond, 0, zap.New(core), clock.timeNow)
+	span := &model.Span{}
+	for i := 0; i < 5; i++ {
+		start := clock.now
+		clock.advance(time.Second)
+		d.observeSpan(span, start)
+	}
+	assert.Equal(t, 5, logs.Len(), "one entry per second is allowed by default")
+
+	for i := 0; i < 5; i++ {
+		d.observeSpan(span, clock.now.Add(-time.Second))
+	}
+	assert.Equal(t, 5, logs.Len())
+}
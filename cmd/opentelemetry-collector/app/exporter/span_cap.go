@@ -0,0 +1,64 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/pkg/cache"
+)
+
+const defaultTraceSpanCapCacheSize = 10000
+
+// spanCapper counts spans per trace and rejects spans once a trace exceeds the cap.
+// Counters live in an LRU cache, so memory is bounded by the number of tracked traces.
+type spanCapper struct {
+	maxSpans int
+	mux      sync.Mutex
+	counts   *cache.LRU
+}
+
+func newSpanCapper(maxSpans int, window time.Duration, cacheSize int, timeNow func() time.Time) *spanCapper {
+	if cacheSize <= 0 {
+		cacheSize = defaultTraceSpanCapCacheSize
+	}
+	return &spanCapper{
+		maxSpans: maxSpans,
+		counts: cache.NewLRUWithOptions(cacheSize, &cache.Options{
+			TTL:     window,
+			TimeNow: timeNow,
+		}),
+	}
+}
+
+// allow records a span of the given trace and returns false if the trace exceeded the cap.
+// The window starts with the first span of the trace, it is not extended by subsequent spans.
+func (c *spanCapper) allow(traceID model.TraceID) bool {
+	key := traceID.String()
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	count, ok := c.counts.Get(key).(*int)
+	if !ok {
+		count = new(int)
+		c.counts.Put(key, count)
+	}
+	if *count >= c.maxSpans {
+		return false
+	}
+	*count++
+	return true
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics/metricstest"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+func TestSpanCapper(t *testing.T) {
+	now := time.Unix(0, 0)
+	capper := newSpanCapper(2, time.Minute, 10, func() time.Time { return now })
+	traceID := model.NewTraceID(1, 2)
+	otherTraceID := model.NewTraceID(1, 3)
+
+	assert.True(t, capper.allow(traceID))
+	assert.True(t, capper.allow(traceID))
+	assert.False(t, capper.allow(traceID))
+	assert.True(t, capper.allow(otherTraceID))
+
+	now = now.Add(2 * time.Minute)
+	assert.True(t, capper.allow(traceID), "counter is reset once the window expires")
+}
+
+func TestSpanCapper_evictsLeastRecentlyUsedTrace(t *testing.T) {
+	capper := newSpanCapper(1, 0, 1, time.Now)
+	traceID := model.NewTraceID(1, 2)
+
+	assert.True(t, capper.allow(traceID))
+	assert.False(t, capper.allow(traceID))
+	assert.True(t, capper.allow(model.NewTraceID(1, 3)))
+	assert.True(t, capper.allow(traceID), "evicted trace is counted from zero")
+}
+
+func TestStore_traceSpanCap(t *testing.T) {
+	tests := []struct {
+		caption  string
+		maxSpans int
+		dropped  int
+	}{
+		{caption: "cap not hit", maxSpans: 3, dropped: 0},
+		{caption: "cap hit", maxSpans: 2, dropped: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			metricsFactory := metricstest.NewFactory(0)
+			s := newStorage(spanWriter{}, Options{MaxSpansPerTrace: test.maxSpans, MetricsFactory: metricsFactory})
+			dropped, err := s.traceDataPusher(context.Background(), tracesWithSpans(
+				&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000001")},
+				&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000002")},
+				&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000003")},
+			))
+			require.NoError(t, err)
+			assert.Equal(t, test.dropped, dropped)
+			metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{
+				Name:  "jaeger_exporter.spans.dropped",
+				Tags:  map[string]string{"reason": "trace_span_cap"},
+				Value: test.dropped,
+			})
+		})
+	}
+}
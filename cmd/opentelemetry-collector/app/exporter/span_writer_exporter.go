@@ -16,59 +16,581 @@ package exporter
 
 import (
 	"context"
+	"errors"
 	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componenterror"
 	"go.opentelemetry.io/collector/config/configmodels"
 	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/consumer/pdata"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
-	jaegertranslator "go.opentelemetry.io/collector/translator/trace/jaeger"
+	"go.uber.org/zap"
 
+	"github.com/jaegertracing/jaeger/model"
 	jaegerstorage "github.com/jaegertracing/jaeger/storage"
 	"github.com/jaegertracing/jaeger/storage/spanstore"
 )
 
+const batchSequenceTag = "jaeger.batch.seq"
+
+// Operations of spans reported to the debug tracer.
+const (
+	debugOperationEnqueue    = "exporter.enqueue"
+	debugOperationFlush      = "exporter.flush_window"
+	debugOperationWriteSpans = "exporter.write_spans"
+)
+
+var (
+	errNilSpanWriter       = errors.New("storage factory returned nil span writer without an error")
+	errNilSpanReader       = errors.New("storage factory returned nil span reader without an error")
+	errDurablePartialSpans = errors.New("durable writes cannot be combined with holding partial spans")
+)
+
+// DurableWriter is a span writer buffering spans, which persists buffered spans on Flush.
+// Flush must fail if any span written since the previous Flush was not persisted.
+// The exporter writes and flushes the spans of one push at a time.
+type DurableWriter interface {
+	Flush(ctx context.Context) error
+}
+
 // NewSpanWriterExporter returns component.TraceExporter
-func NewSpanWriterExporter(config configmodels.Exporter, factory jaegerstorage.Factory) (component.TraceExporter, error) {
+func NewSpanWriterExporter(config configmodels.Exporter, factory jaegerstorage.Factory, opts Options) (component.TraceExporter, error) {
 	spanWriter, err := factory.CreateSpanWriter()
 	if err != nil {
 		return nil, err
 	}
-	storage := storage{Writer: spanWriter}
+	if spanWriter == nil {
+		return nil, errNilSpanWriter
+	}
+	if opts.DurableWrites && opts.PartialSpanWindow > 0 {
+		return nil, errDurablePartialSpans
+	}
+	storage := newStorage(spanWriter, opts)
+	if opts.IndexTemplate != "" {
+		if storage.indexed, err = newIndexedWrites(opts.IndexTemplate, spanWriter); err != nil {
+			return nil, err
+		}
+	}
+	if opts.VerifySpan != nil {
+		spanReader, err := factory.CreateSpanReader()
+		if err != nil {
+			return nil, err
+		}
+		if spanReader == nil {
+			return nil, errNilSpanReader
+		}
+		storage.verifier = newWriteVerifier(spanReader, opts.VerifySpan, exporterMetricsFactory(opts), storage.logger)
+	}
+	if storage.batcher != nil {
+		storage.batcher.start()
+	}
+	if storage.partials != nil {
+		storage.partials.start()
+	}
 	return exporterhelper.NewTraceExporter(
 		config,
 		storage.traceDataPusher,
-		exporterhelper.WithShutdown(func(context.Context) error {
-			if closer, ok := spanWriter.(io.Closer); ok {
-				return closer.Close()
-			}
-			return nil
-		}))
+		exporterhelper.WithShutdown(storage.shutdown))
 }
 
 type storage struct {
-	Writer spanstore.Writer
+	Writer        spanstore.Writer
+	rootWriter    spanstore.Writer
+	mirror        *mirror
+	logger        *zap.Logger
+	converter     converter
+	spanCapper    *spanCapper
+	batcher       *windowBatcher
+	partials      *partialSpanMerger
+	indexed       *indexedWrites
+	throttler     *writeThrottler
+	durable       bool
+	durableWriter DurableWriter
+	// durableMux is held across writing and flushing spans of a push to a DurableWriter,
+	// so that the flush result applies to the spans of that push only.
+	durableMux      sync.Mutex
+	tagValueLengths *tagValueLengths
+	verifier        *writeVerifier
+	writeErrorLog   *rateLimitedLogger
+	writeTimeout    time.Duration
+	writeTimeoutLog *rateLimitedLogger
+	slowWrites      *slowWriteDetector
+	manifests       *manifestRecorder
+	dropOrphans     bool
+	dropInternal    bool
+	keepRootSpans   bool
+	duplicates      DuplicateSpanMode
+	schema          *processSchema
+	retention       time.Duration
+	timeNow         func() time.Time
+	batchSequence   *uint64
+	debugTracer     opentracing.Tracer
+	metrics         storageMetrics
+	totals          *lifetimeTotals
+	logSummary      bool
+}
+
+func newStorage(writer spanstore.Writer, opts Options) *storage {
+	metricsFactory := exporterMetricsFactory(opts)
+	s := &storage{
+		Writer:        writer,
+		rootWriter:    opts.RootSpanWriter,
+		logger:        opts.Logger,
+		converter:     newConverter(opts),
+		dropOrphans:   opts.DropOrphanedSpans,
+		dropInternal:  opts.DropInternalSpans,
+		keepRootSpans: opts.KeepInternalRootSpans,
+		duplicates:    opts.DuplicateSpans,
+		retention:     opts.DropBeyondRetention,
+		timeNow:       time.Now,
+		debugTracer:   opts.DebugTracer,
+		totals:        &lifetimeTotals{},
+		logSummary:    opts.LogShutdownSummary,
+	}
+	s.metrics = s.totals.withTotals(newStorageMetrics(metricsFactory))
+	if s.logger == nil {
+		s.logger = zap.NewNop()
+	}
+	if s.debugTracer == nil {
+		s.debugTracer = opentracing.NoopTracer{}
+	}
+	if opts.MirrorWriter != nil && opts.MirrorPercentage > 0 {
+		s.mirror = newMirror(opts.MirrorWriter, opts.MirrorPercentage, opts.MirrorQueueSize, metricsFactory)
+	}
+	if len(opts.RequiredProcessTags) > 0 {
+		s.schema = newProcessSchema(opts.RequiredProcessTags, opts.SchemaViolations)
+	}
+	if opts.BatchSequenceTag {
+		s.batchSequence = new(uint64)
+	}
+	if opts.MaxSpansPerTrace > 0 {
+		s.spanCapper = newSpanCapper(opts.MaxSpansPerTrace, opts.TraceSpanCapWindow, opts.TraceSpanCapCacheSize, time.Now)
+	}
+	if opts.WriteErrorLogsPerSecond > 0 {
+		s.writeErrorLog = newRateLimitedLogger(opts.WriteErrorLogsPerSecond, time.Now)
+	}
+	if opts.WriteTimeout > 0 {
+		s.writeTimeout = opts.WriteTimeout
+		s.writeTimeoutLog = newRateLimitedLogger(1, time.Now)
+	}
+	if opts.SlowWriteThreshold > 0 {
+		s.slowWrites = newSlowWriteDetector(opts.SlowWriteThreshold, opts.SlowWriteLogsPerSecond, s.logger, time.Now)
+	}
+	if opts.ManifestWriter != nil {
+		s.manifests = newManifestRecorder(opts.ManifestWriter, time.Now)
+	}
+	if opts.RecordTagValueLengths {
+		s.tagValueLengths = newTagValueLengths(metricsFactory, opts.TagValueLengthKeys)
+	}
+	if opts.BatchWindow > 0 {
+		s.batcher = newWindowBatcher(opts.BatchWindow, opts.BatchWindowMaxSpans, s.flushWindow, s.metrics.SpansPending[pendingQueueBatchWindow], time.Now)
+	}
+	if opts.DurableWrites {
+		s.durable = true
+		var ok bool
+		if s.durableWriter, ok = writer.(DurableWriter); !ok {
+			s.logger.Warn("Span writer does not implement DurableWriter, durable writes assume spans are persisted once written")
+		}
+	}
+	if opts.MaxSpansPerSecond > 0 {
+		s.throttler = newWriteThrottler(opts.MaxSpansPerSecond, opts.SpansPerSecondBurst, opts.Throttle, time.Now)
+	}
+	if opts.PartialSpanWindow > 0 {
+		s.partials = newPartialSpanMerger(opts.PartialSpanWindow, s.flushPartialSpans, s.metrics.SpansPending[pendingQueuePartialSpans], time.Now)
+	}
+	return s
 }
 
 // traceDataPusher implements OTEL exporterhelper.traceDataPusher
 func (s *storage) traceDataPusher(ctx context.Context, td pdata.Traces) (droppedSpans int, err error) {
-	batches, err := jaegertranslator.InternalTracesToJaegerProto(td)
+	batches, err := s.converter.convert(td)
 	if err != nil {
+		s.metrics.SpansConversionFailed[classifyConversionFailure(td)].Inc(int64(td.SpanCount()))
 		return td.SpanCount(), consumererror.Permanent(err)
 	}
+	var kinds []pdata.SpanKind
+	if s.dropInternal {
+		kinds = spanKinds(td)
+	}
+	var unfinished []bool
+	if s.partials != nil {
+		unfinished = unfinishedSpans(td)
+	}
 	dropped := 0
-	var errs []error
+	var filtered spanKeySet
+	var partial partialSpanSet
+	spans := make([]*model.Span, 0, td.SpanCount())
+	i := 0
 	for _, batch := range batches {
 		for _, span := range batch.Spans {
 			span.Process = batch.Process
-			err := s.Writer.WriteSpan(span)
-			if err != nil {
-				errs = append(errs, err)
+			kind := pdata.SpanKindUNSPECIFIED
+			if kinds != nil {
+				kind = kinds[i]
+			}
+			if unfinished != nil && unfinished[i] {
+				partial = partial.add(span)
+			}
+			i++
+			if s.schema != nil && !s.schema.drop && s.schema.violated(span.Process) {
+				span.Tags = append(span.Tags, model.Bool(schemaViolationTag, true))
+			}
+			if reason := s.dropReason(span, kind); reason != "" {
+				s.metrics.SpansDropped[reason].Inc(1)
 				dropped++
+				if s.dropOrphans {
+					filtered = filtered.add(span)
+				}
+				continue
+			}
+			spans = append(spans, span)
+		}
+	}
+	if len(filtered) > 0 {
+		var orphans int
+		spans, orphans = dropOrphans(spans, filtered)
+		s.metrics.SpansDropped[dropReasonParentDropped].Inc(int64(orphans))
+		dropped += orphans
+	}
+	if s.partials != nil {
+		spans = s.partials.merge(spans, partial)
+	}
+	writeDropped, err := s.writeMerged(ctx, spans)
+	return dropped + writeDropped, err
+}
+
+// writeMerged writes spans which passed the filters and are not held as partial spans,
+// and returns the number of spans which were dropped or failed to be written.
+func (s *storage) writeMerged(ctx context.Context, spans []*model.Span) (dropped int, err error) {
+	if s.duplicates != DuplicateSpansKeep {
+		var duplicates int
+		spans, duplicates = removeDuplicates(spans, s.duplicates == DuplicateSpansMerge)
+		if s.duplicates == DuplicateSpansDrop {
+			s.metrics.SpansDropped[dropReasonIntraBatchDuplicate].Inc(int64(duplicates))
+			dropped += duplicates
+		}
+	}
+	if s.throttler != nil {
+		admitted, shed, err := s.throttler.admit(ctx, spans)
+		if err != nil {
+			s.metrics.SpansDropped[dropReasonContextCancelled].Inc(int64(len(spans)))
+			return dropped + len(spans), err
+		}
+		spans = admitted
+		s.metrics.SpansDropped[dropReasonGlobalRateLimited].Inc(int64(shed))
+		dropped += shed
+	}
+	if s.batchSequence != nil {
+		seq := int64(atomic.AddUint64(s.batchSequence, 1))
+		for _, span := range spans {
+			span.Tags = append(span.Tags, model.Int64(batchSequenceTag, seq))
+		}
+	}
+	if s.tagValueLengths != nil {
+		for _, span := range spans {
+			s.tagValueLengths.record(span)
+		}
+	}
+	if s.batcher != nil {
+		debugSpan := s.startDebugSpan(debugOperationEnqueue, len(spans))
+		result := s.batcher.add(spans)
+		debugSpan.Finish()
+		if s.durable && result != nil {
+			if err := result.wait(ctx); err != nil {
+				return dropped + len(spans), err
+			}
+		}
+		return dropped, nil
+	}
+	failed, err := s.writeSpans(ctx, spans)
+	return dropped + failed, err
+}
+
+// dropReason returns the reason for dropping the span, or an empty string if the span should be written.
+// The kind is the OTLP span kind, which is only known if a filter needs it.
+func (s *storage) dropReason(span *model.Span, kind pdata.SpanKind) string {
+	if s.dropInternal && kind == pdata.SpanKindINTERNAL && !(s.keepRootSpans && span.ParentSpanID() == 0) {
+		return dropReasonInternalFiltered
+	}
+	if s.retention > 0 && span.StartTime.Before(s.timeNow().Add(-s.retention)) {
+		return dropReasonBeyondRetention
+	}
+	if s.schema != nil && s.schema.drop && s.schema.violated(span.Process) {
+		return dropReasonSchemaViolation
+	}
+	if s.spanCapper != nil && !s.spanCapper.allow(span.TraceID) {
+		return dropReasonTraceSpanCap
+	}
+	return ""
+}
+
+// writeSpans writes spans to the storage and returns the number of spans which failed to be written.
+func (s *storage) writeSpans(ctx context.Context, spans []*model.Span) (failed int, err error) {
+	debugSpan := s.startDebugSpan(debugOperationWriteSpans, len(spans))
+	defer finishDebugSpan(debugSpan, &failed)
+	parentCtx := ctx
+	if s.writeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.writeTimeout)
+		defer cancel()
+	}
+	var errs []error
+	var failures writeFailures
+	var written []*model.Span
+	var batchStart time.Time
+	var timedOut bool
+	attempted := len(spans)
+	if s.durableWriter != nil {
+		s.durableMux.Lock()
+		defer s.durableMux.Unlock()
+	}
+	if s.slowWrites != nil {
+		batchStart = s.slowWrites.timeNow()
+	}
+	for i, span := range spans {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			skipped := len(spans) - i
+			s.metrics.SpansDropped[dropReasonContextCancelled].Inc(int64(skipped))
+			if s.writeTimeout > 0 && parentCtx.Err() == nil {
+				timedOut = true
+				s.metrics.WriteTimeouts.Inc(1)
+				s.logWriteTimeout(i, skipped)
+			}
+			errs = append(errs, ctxErr)
+			failed += skipped
+			attempted = i
+			break
+		}
+		err := s.writeSpan(span)
+		if err != nil {
+			errs = append(errs, err)
+			failed++
+			atomic.AddInt64(&s.totals.failed, 1)
+			if s.writeErrorLog != nil {
+				failures.add(span, err)
 			}
+			continue
+		}
+		written = append(written, span)
+	}
+	// the timeout may also expire during the last write, when no spans are left to skip
+	if !timedOut && s.writeTimeout > 0 && ctx.Err() != nil && parentCtx.Err() == nil {
+		s.metrics.WriteTimeouts.Inc(1)
+		s.logWriteTimeout(len(spans), 0)
+	}
+	if s.slowWrites != nil {
+		s.slowWrites.observeBatch(spans, batchStart)
+	}
+	// mirrored writes are queued, they do not count against the write timeout of the primary writer
+	if s.mirror != nil {
+		for _, span := range spans[:attempted] {
+			s.mirror.write(span)
+		}
+	}
+	// the flush is not subject to the write timeout, an expired context would lose buffered spans
+	if s.durableWriter != nil && len(written) > 0 {
+		if err := s.durableWriter.Flush(parentCtx); err != nil {
+			errs = append(errs, err)
+			failed += len(written)
+			atomic.AddInt64(&s.totals.failed, int64(len(written)))
+			// buffered spans may not be persisted, therefore they are neither counted as written
+			// nor recorded in the manifest
+			written = nil
+		}
+	}
+	s.metrics.SpansWritten.Inc(int64(len(written)))
+	// spans are verified once flushed, a DurableWriter does not persist them before
+	if s.verifier != nil {
+		for _, span := range written {
+			s.verifier.verify(ctx, span)
+		}
+	}
+	s.logWriteFailures(failures)
+	if s.rootWriter != nil {
+		s.writeRootSpans(spans)
+	}
+	if s.manifests != nil {
+		if err := s.manifests.record(written); err != nil {
+			s.logger.Error("Failed to write batch manifest", zap.Int("spans", len(written)), zap.Error(err))
+		}
+	}
+	return failed, componenterror.CombineErrors(errs)
+}
+
+func (s *storage) writeSpan(span *model.Span) error {
+	if s.slowWrites == nil {
+		return s.writePrimary(span)
+	}
+	start := s.slowWrites.timeNow()
+	err := s.writePrimary(span)
+	s.slowWrites.observeSpan(span, start)
+	return err
+}
+
+func (s *storage) writePrimary(span *model.Span) error {
+	if s.indexed != nil {
+		return s.indexed.writeSpan(span)
+	}
+	return s.Writer.WriteSpan(span)
+}
+
+// writeFailures groups write errors by service.
+type writeFailures struct {
+	services  []string
+	byService map[string]*serviceWriteFailure
+}
+
+type serviceWriteFailure struct {
+	spans   int
+	lastErr error
+}
+
+func (f *writeFailures) add(span *model.Span, err error) {
+	service := ""
+	if span.Process != nil {
+		service = span.Process.ServiceName
+	}
+	if f.byService == nil {
+		f.byService = make(map[string]*serviceWriteFailure)
+	}
+	failure, ok := f.byService[service]
+	if !ok {
+		failure = &serviceWriteFailure{}
+		f.byService[service] = failure
+		f.services = append(f.services, service)
+	}
+	failure.spans++
+	failure.lastErr = err
+}
+
+// logWriteFailures logs failed writes of each service, subject to the log rate limit.
+func (s *storage) logWriteFailures(failures writeFailures) {
+	for _, service := range failures.services {
+		if !s.writeErrorLog.allow() {
+			return
+		}
+		failure := failures.byService[service]
+		s.logger.Error("Failed to write spans",
+			zap.String("service", service),
+			zap.Int("spans", failure.spans),
+			zap.Error(failure.lastErr))
+	}
+}
+
+// flushPartialSpans writes partial spans whose other parts did not arrive within the window,
+// the same way as spans of a push which are not held.
+func (s *storage) flushPartialSpans(spans []*model.Span) {
+	if dropped, err := s.writeMerged(context.Background(), spans); err != nil {
+		s.logger.Error("Failed to write partial spans", zap.Int("dropped", dropped), zap.Int("spans", len(spans)), zap.Error(err))
+	}
+}
+
+// writeRootSpans writes root spans to the root span writer.
+// The spans are already stored by the primary writer, therefore errors are only logged.
+func (s *storage) writeRootSpans(spans []*model.Span) {
+	for _, span := range rootSpans(spans) {
+		if err := s.rootWriter.WriteSpan(span); err != nil {
+			s.logger.Error("Failed to write root span", zap.Stringer("trace_id", span.TraceID), zap.Error(err))
+		}
+	}
+}
+
+// logWriteTimeout logs a write which exceeded the write timeout, subject to the log rate limit.
+func (s *storage) logWriteTimeout(written, skipped int) {
+	if s.writeTimeoutLog.allow() {
+		s.logger.Warn("Write timeout exceeded, skipping remaining spans",
+			zap.Duration("timeout", s.writeTimeout),
+			zap.Int("written", written),
+			zap.Int("skipped", skipped))
+	}
+}
+
+// flushWindow writes spans accumulated by the window batcher.
+// Errors are logged, and returned to durable pushes waiting for the window.
+// Other pushes which added the spans have already returned.
+func (s *storage) flushWindow(spans []*model.Span) error {
+	debugSpan := s.startDebugSpan(debugOperationFlush, len(spans))
+	defer debugSpan.Finish()
+	failed, err := s.writeSpans(context.Background(), spans)
+	if err != nil {
+		s.logger.Error("Failed to write batch window", zap.Int("failed", failed), zap.Int("spans", len(spans)), zap.Error(err))
+	}
+	return err
+}
+
+// startDebugSpan starts a span of an internal operation on the debug tracer.
+// Debug spans are never written to the storage of the exporter.
+func (s *storage) startDebugSpan(operation string, spans int) opentracing.Span {
+	span := s.debugTracer.StartSpan(operation)
+	span.SetTag("spans", spans)
+	return span
+}
+
+func finishDebugSpan(span opentracing.Span, failed *int) {
+	if *failed > 0 {
+		ext.Error.Set(span, true)
+		span.SetTag("failed", *failed)
+	}
+	span.Finish()
+}
+
+func (s *storage) shutdown(context.Context) error {
+	if s.partials != nil {
+		s.partials.close()
+	}
+	if s.batcher != nil {
+		s.batcher.close()
+	}
+	if s.mirror != nil {
+		s.mirror.close()
+	}
+	if s.logSummary {
+		s.totals.log(s.logger)
+	}
+	writers := []interface{}{s.Writer, s.rootWriter}
+	if s.mirror != nil {
+		writers = append(writers, s.mirror.writer)
+	}
+	if s.manifests != nil {
+		writers = append(writers, s.manifests.writer)
+	}
+	return closeWriters(writers)
+}
+
+// closeWriters closes writers implementing io.Closer,
+// a writer configured for several purposes is closed once.
+func closeWriters(writers []interface{}) error {
+	var errs []error
+	var closed []io.Closer
+	for _, writer := range writers {
+		closer, ok := writer.(io.Closer)
+		if !ok || containsCloser(closed, closer) {
+			continue
+		}
+		closed = append(closed, closer)
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return componenterror.CombineErrors(errs)
+}
+
+func containsCloser(closers []io.Closer, closer io.Closer) bool {
+	// comparing values of uncomparable types panics, such writers are closed every time
+	if !reflect.TypeOf(closer).Comparable() {
+		return false
+	}
+	for _, c := range closers {
+		if c == closer {
+			return true
 		}
 	}
-	return dropped, componenterror.CombineErrors(errs)
+	return false
 }
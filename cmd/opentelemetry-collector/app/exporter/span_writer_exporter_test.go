@@ -17,15 +17,22 @@ package exporter
 import (
 	"context"
 	"errors"
+	"expvar"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/uber/jaeger-lib/metrics"
+	"github.com/uber/jaeger-lib/metrics/metricstest"
 	"go.opentelemetry.io/collector/config/configmodels"
 	"go.opentelemetry.io/collector/consumer/pdata"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/jaegertracing/jaeger/model"
 	"github.com/jaegertracing/jaeger/storage/dependencystore"
@@ -33,30 +40,36 @@ import (
 )
 
 func TestNew_closableWriter(t *testing.T) {
-	exporter, err := NewSpanWriterExporter(&configmodels.ExporterSettings{}, mockStorageFactory{spanWriter: spanWriter{}})
+	exporter, err := NewSpanWriterExporter(&configmodels.ExporterSettings{}, mockStorageFactory{spanWriter: spanWriter{}}, Options{})
 	require.NoError(t, err)
 	assert.NotNil(t, exporter)
 	assert.Nil(t, exporter.Shutdown(context.Background()))
 }
 
 func TestNew_noClosableWriter(t *testing.T) {
-	exporter, err := NewSpanWriterExporter(&configmodels.ExporterSettings{}, mockStorageFactory{spanWriter: noClosableWriter{}})
+	exporter, err := NewSpanWriterExporter(&configmodels.ExporterSettings{}, mockStorageFactory{spanWriter: noClosableWriter{}}, Options{})
 	require.NoError(t, err)
 	assert.NotNil(t, exporter)
 	assert.Nil(t, exporter.Shutdown(context.Background()))
 }
 
 func TestNew_failedToCreateWriter(t *testing.T) {
-	exporter, err := NewSpanWriterExporter(&configmodels.ExporterSettings{}, mockStorageFactory{err: errors.New("failed to create writer"), spanWriter: spanWriter{}})
+	exporter, err := NewSpanWriterExporter(&configmodels.ExporterSettings{}, mockStorageFactory{err: errors.New("failed to create writer"), spanWriter: spanWriter{}}, Options{})
 	require.Nil(t, exporter)
 	assert.Error(t, err, "failed to create writer")
 }
 
+func TestNew_nilWriter(t *testing.T) {
+	exporter, err := NewSpanWriterExporter(&configmodels.ExporterSettings{}, mockStorageFactory{}, Options{})
+	require.Nil(t, exporter)
+	assert.EqualError(t, err, "storage factory returned nil span writer without an error")
+}
+
 func TestStore(t *testing.T) {
 	traceID := []byte("0123456789abcdef")
 	spanID := []byte("01234567")
 	tests := []struct {
-		storage storage
+		storage *storage
 		data    pdata.Traces
 		err     string
 		dropped int
@@ -64,20 +77,20 @@ func TestStore(t *testing.T) {
 	}{
 		{
 			caption: "nothing to store",
-			storage: storage{Writer: spanWriter{}},
+			storage: newStorage(spanWriter{}, Options{}),
 			data:    pdata.TracesFromOtlp([]*tracev1.ResourceSpans{}),
 			dropped: 0,
 		},
 		{
 			caption: "wrong data",
-			storage: storage{Writer: spanWriter{}},
+			storage: newStorage(spanWriter{}, Options{}),
 			data:    pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: []*tracev1.Span{{}}}}}}),
 			err:     "TraceID is nil",
 			dropped: 1,
 		},
 		{
 			caption: "one error in writer",
-			storage: storage{Writer: spanWriter{err: errors.New("could not store")}},
+			storage: newStorage(spanWriter{err: errors.New("could not store")}, Options{}),
 			data: pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
 				InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{
 					Spans: []*tracev1.Span{
@@ -91,7 +104,7 @@ func TestStore(t *testing.T) {
 		},
 		{
 			caption: "two errors in writer",
-			storage: storage{Writer: spanWriter{err: errors.New("could not store")}},
+			storage: newStorage(spanWriter{err: errors.New("could not store")}, Options{}),
 			data: pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
 				InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{
 					Spans: []*tracev1.Span{
@@ -117,6 +130,237 @@ func TestStore(t *testing.T) {
 	}
 }
 
+func TestStore_contextCancelledMidBatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	writer := &cancellingWriter{cancelAfter: 2, cancel: cancel}
+	metricsFactory := metricstest.NewFactory(0)
+	s := newStorage(writer, Options{MetricsFactory: metricsFactory})
+	spans := make([]*tracev1.Span, 5)
+	for i := range spans {
+		spans[i] = &tracev1.Span{TraceId: []byte("0123456789abcdef"), SpanId: []byte("01234567")}
+	}
+
+	dropped, err := s.traceDataPusher(ctx, pdata.TracesFromOtlp([]*tracev1.ResourceSpans{{
+		InstrumentationLibrarySpans: []*tracev1.InstrumentationLibrarySpans{{Spans: spans}},
+	}}))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 3, dropped)
+	assert.Equal(t, 2, writer.written)
+	metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{
+		Name:  "jaeger_exporter.spans.dropped",
+		Tags:  map[string]string{"reason": "context_cancelled"},
+		Value: 3,
+	})
+}
+
+func TestStore_dropInternalSpans(t *testing.T) {
+	span := func(id, parentID string, kind tracev1.Span_SpanKind) *tracev1.Span {
+		return &tracev1.Span{TraceId: testTraceID, SpanId: []byte(id), ParentSpanId: []byte(parentID), Kind: kind}
+	}
+	td := tracesWithSpans(
+		span("0000000A", "", tracev1.Span_INTERNAL),
+		span("0000000B", "0000000A", tracev1.Span_CLIENT),
+		span("0000000C", "0000000A", tracev1.Span_INTERNAL),
+		span("0000000D", "0000000A", tracev1.Span_SPAN_KIND_UNSPECIFIED),
+	)
+	tests := []struct {
+		caption string
+		options Options
+		written []model.SpanID
+	}{
+		{
+			caption: "internal spans kept by default",
+			written: []model.SpanID{0x3030303030303041, 0x3030303030303042, 0x3030303030303043, 0x3030303030303044},
+		},
+		{
+			caption: "internal spans dropped",
+			options: Options{DropInternalSpans: true},
+			written: []model.SpanID{0x3030303030303042, 0x3030303030303044},
+		},
+		{
+			caption: "internal root spans kept",
+			options: Options{DropInternalSpans: true, KeepInternalRootSpans: true},
+			written: []model.SpanID{0x3030303030303041, 0x3030303030303042, 0x3030303030303044},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			writer := &recordingWriter{}
+			metricsFactory := metricstest.NewFactory(0)
+			test.options.MetricsFactory = metricsFactory
+			s := newStorage(writer, test.options)
+			dropped, err := s.traceDataPusher(context.Background(), td)
+			require.NoError(t, err)
+			filtered := 4 - len(test.written)
+			assert.Equal(t, filtered, dropped)
+			var written []model.SpanID
+			for _, span := range writer.written() {
+				written = append(written, span.SpanID)
+			}
+			assert.Equal(t, test.written, written)
+			metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{
+				Name:  "jaeger_exporter.spans.dropped",
+				Tags:  map[string]string{"reason": "internal_filtered"},
+				Value: filtered,
+			})
+		})
+	}
+}
+
+func TestStore_batchSequenceTag(t *testing.T) {
+	writer := &recordingWriter{}
+	s := newStorage(writer, Options{BatchSequenceTag: true})
+	for i := 0; i < 3; i++ {
+		_, err := s.traceDataPusher(context.Background(), tracesWithSpans(
+			&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000001")},
+			&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000002")},
+		))
+		require.NoError(t, err)
+	}
+	var sequence []int64
+	for _, span := range writer.written() {
+		tag, ok := model.KeyValues(span.Tags).FindByKey("jaeger.batch.seq")
+		require.True(t, ok)
+		sequence = append(sequence, tag.Int64())
+	}
+	assert.Equal(t, []int64{1, 1, 2, 2, 3, 3}, sequence)
+
+	other := &recordingWriter{}
+	s = newStorage(other, Options{BatchSequenceTag: true})
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}))
+	require.NoError(t, err)
+	assert.Equal(t, model.Int64("jaeger.batch.seq", 1), other.written()[0].Tags[0], "sequence is per exporter")
+}
+
+func TestStore_writeTimeout(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	metricsFactory := metricstest.NewFactory(0)
+	s := newStorage(sleepingWriter{delay: 50 * time.Millisecond}, Options{
+		WriteTimeout:   10 * time.Millisecond,
+		Logger:         zap.New(core),
+		MetricsFactory: metricsFactory,
+	})
+	td := tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000001")},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000002")},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000003")},
+	)
+	dropped, err := s.traceDataPusher(context.Background(), td)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 2, dropped)
+	metricsFactory.AssertCounterMetrics(t,
+		metricstest.ExpectedMetric{Name: "jaeger_exporter.write_timeouts", Value: 1},
+		metricstest.ExpectedMetric{Name: "jaeger_exporter.spans.dropped", Tags: map[string]string{"reason": "context_cancelled"}, Value: 2},
+	)
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]interface{}{
+		"timeout": 10 * time.Millisecond,
+		"written": int64(1),
+		"skipped": int64(2),
+	}, logs.All()[0].ContextMap())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.traceDataPusher(ctx, td)
+	metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{Name: "jaeger_exporter.write_timeouts", Value: 1})
+}
+
+func TestStore_writeTimeoutDuringLastWrite(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	metricsFactory := metricstest.NewFactory(0)
+	s := newStorage(sleepingWriter{delay: 50 * time.Millisecond}, Options{
+		WriteTimeout:   10 * time.Millisecond,
+		Logger:         zap.New(core),
+		MetricsFactory: metricsFactory,
+	})
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000001")},
+	))
+	require.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{Name: "jaeger_exporter.write_timeouts", Value: 1})
+	require.Equal(t, 1, logs.Len())
+	assert.Equal(t, map[string]interface{}{
+		"timeout": 10 * time.Millisecond,
+		"written": int64(1),
+		"skipped": int64(0),
+	}, logs.All()[0].ContextMap())
+}
+
+func TestStore_dropBeyondRetention(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(100000, 0)}
+	writer := &recordingWriter{}
+	metricsFactory := metricstest.NewFactory(0)
+	s := newStorage(writer, Options{DropBeyondRetention: time.Hour, MetricsFactory: metricsFactory})
+	s.timeNow = clock.timeNow
+	start := func(t time.Time) uint64 {
+		return uint64(t.UnixNano())
+	}
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000001"), StartTimeUnixNano: start(clock.now.Add(-2 * time.Hour))},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000002"), StartTimeUnixNano: start(clock.now.Add(-time.Minute))},
+	))
+	require.NoError(t, err)
+	assert.Equal(t, 1, dropped)
+	written := writer.written()
+	require.Len(t, written, 1)
+	assert.Equal(t, model.SpanID(0x3030303030303032), written[0].SpanID)
+	metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{
+		Name:  "jaeger_exporter.spans.dropped",
+		Tags:  map[string]string{"reason": "beyond_retention"},
+		Value: 1,
+	})
+}
+
+func TestStore_expvarMetrics(t *testing.T) {
+	metricsFactory := metricstest.NewFactory(0)
+	// the second exporter reuses published variables
+	storages := []*storage{
+		newStorage(&recordingWriter{}, Options{ExpvarMetrics: true, MetricsFactory: metricsFactory}),
+		newStorage(&recordingWriter{}, Options{ExpvarMetrics: true, MetricsFactory: metricsFactory}),
+	}
+	// published variables are global, they may have been incremented by previous runs of the test
+	written := expvar.Get("jaeger_exporter.spans.written")
+	require.NotNil(t, written)
+	before, err := strconv.ParseFloat(written.String(), 64)
+	require.NoError(t, err)
+	for _, s := range storages {
+		_, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}))
+		require.NoError(t, err)
+	}
+	after, err := strconv.ParseFloat(written.String(), 64)
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), after-before)
+	assert.NotNil(t, expvar.Get("jaeger_exporter.spans.dropped.reason_trace_span_cap"))
+	assert.NotNil(t, expvar.Get("jaeger_exporter.spans.pending.queue_batch_window"))
+	metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{Name: "jaeger_exporter.spans.written", Value: 2})
+}
+
+func TestMetricsFactory_sharedByExporters(t *testing.T) {
+	for _, name := range []string{"jaeger_cassandra", "jaeger_kafka", "jaeger_cassandra"} {
+		s := newStorage(&recordingWriter{}, Options{MetricsFactory: MetricsFactory(name)})
+		_, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}))
+		require.NoError(t, err)
+	}
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	exporters := map[string]bool{}
+	for _, family := range families {
+		if family.GetName() != "jaeger_exporter_spans_written_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "exporter" {
+					exporters[label.GetValue()] = true
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]bool{"jaeger_cassandra": true, "jaeger_kafka": true}, exporters)
+}
+
 type spanWriter struct {
 	err error
 }
@@ -132,6 +376,30 @@ func (spanWriter) Close() error {
 	return nil
 }
 
+// cancellingWriter cancels the push context once it wrote cancelAfter spans.
+type cancellingWriter struct {
+	cancelAfter int
+	cancel      context.CancelFunc
+	written     int
+}
+
+func (w *cancellingWriter) WriteSpan(span *model.Span) error {
+	w.written++
+	if w.written == w.cancelAfter {
+		w.cancel()
+	}
+	return nil
+}
+
+type sleepingWriter struct {
+	delay time.Duration
+}
+
+func (w sleepingWriter) WriteSpan(span *model.Span) error {
+	time.Sleep(w.delay)
+	return nil
+}
+
 type noClosableWriter struct {
 }
 
@@ -139,16 +407,36 @@ func (noClosableWriter) WriteSpan(span *model.Span) error {
 	return nil
 }
 
+// recordingWriter keeps written spans.
+type recordingWriter struct {
+	mux   sync.Mutex
+	spans []*model.Span
+}
+
+func (w *recordingWriter) WriteSpan(span *model.Span) error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.spans = append(w.spans, span)
+	return nil
+}
+
+func (w *recordingWriter) written() []*model.Span {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return append([]*model.Span(nil), w.spans...)
+}
+
 type mockStorageFactory struct {
 	err        error
+	readerErr  error
 	spanWriter spanstore.Writer
 }
 
 func (m mockStorageFactory) CreateSpanWriter() (spanstore.Writer, error) {
 	return m.spanWriter, m.err
 }
-func (mockStorageFactory) CreateSpanReader() (spanstore.Reader, error) {
-	return nil, nil
+func (m mockStorageFactory) CreateSpanReader() (spanstore.Reader, error) {
+	return nil, m.readerErr
 }
 func (mockStorageFactory) CreateDependencyReader() (dependencystore.Reader, error) {
 	return nil, nil
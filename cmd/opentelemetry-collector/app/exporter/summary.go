@@ -0,0 +1,79 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sync/atomic"
+
+	"github.com/uber/jaeger-lib/metrics"
+	"go.uber.org/zap"
+)
+
+// filterDropReasons are drop reasons of spans removed by filters, as opposed to spans lost
+// because they could not be written in time.
+var filterDropReasons = map[string]bool{
+	dropReasonTraceSpanCap:        true,
+	dropReasonParentDropped:       true,
+	dropReasonInternalFiltered:    true,
+	dropReasonIntraBatchDuplicate: true,
+	dropReasonSchemaViolation:     true,
+	dropReasonBeyondRetention:     true,
+}
+
+// lifetimeTotals counts spans handled by the exporter since it was created.
+// Each span is counted in one of the totals only.
+type lifetimeTotals struct {
+	written  int64
+	failed   int64
+	dropped  int64
+	filtered int64
+}
+
+// totalingCounter is a counter which also adds to a lifetime total.
+type totalingCounter struct {
+	metrics.Counter
+	total *int64
+}
+
+func (c totalingCounter) Inc(delta int64) {
+	c.Counter.Inc(delta)
+	atomic.AddInt64(c.total, delta)
+}
+
+// withTotals wraps metrics to add to the totals.
+func (t *lifetimeTotals) withTotals(m storageMetrics) storageMetrics {
+	for reason, counter := range m.SpansDropped {
+		total := &t.dropped
+		if filterDropReasons[reason] {
+			total = &t.filtered
+		}
+		m.SpansDropped[reason] = totalingCounter{Counter: counter, total: total}
+	}
+	for cause, counter := range m.SpansConversionFailed {
+		m.SpansConversionFailed[cause] = totalingCounter{Counter: counter, total: &t.dropped}
+	}
+	m.SpansWritten = totalingCounter{Counter: m.SpansWritten, total: &t.written}
+	return m
+}
+
+// log logs the totals in one entry. Failed spans are spans whose write returned an error,
+// whether they were written on push, in a batch window or as expired partial spans.
+func (t *lifetimeTotals) log(logger *zap.Logger) {
+	logger.Info("Span writer exporter summary",
+		zap.Int64("written", atomic.LoadInt64(&t.written)),
+		zap.Int64("failed", atomic.LoadInt64(&t.failed)),
+		zap.Int64("dropped", atomic.LoadInt64(&t.dropped)),
+		zap.Int64("filtered", atomic.LoadInt64(&t.filtered)))
+}
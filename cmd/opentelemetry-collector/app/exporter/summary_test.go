@@ -0,0 +1,91 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStore_logsShutdownSummary(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	s := newStorage(spanWriter{err: errors.New("could not store")}, Options{
+		Logger:             zap.New(core),
+		LogShutdownSummary: true,
+		DuplicateSpans:     DuplicateSpansDrop,
+	})
+
+	s.traceDataPusher(context.Background(), tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("76543210"), Name: "error"},
+		&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID},
+		&tracev1.Span{TraceId: testTraceID, SpanId: []byte("00000001")},
+	))
+	s.traceDataPusher(context.Background(), tracesWithSpans(
+		&tracev1.Span{SpanId: testSpanID},
+		&tracev1.Span{SpanId: testSpanID},
+	))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.traceDataPusher(ctx, tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID}))
+	assert.Zero(t, logs.Len(), "summary is logged on shutdown only")
+
+	require.NoError(t, s.shutdown(context.Background()))
+	entries := logs.FilterMessage("Span writer exporter summary").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, map[string]interface{}{
+		"written":  int64(2),
+		"failed":   int64(1),
+		"dropped":  int64(3),
+		"filtered": int64(1),
+	}, entries[0].ContextMap())
+}
+
+func TestStore_shutdownSummaryCountsFailedWindowWrites(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	s := newStorage(spanWriter{err: errors.New("could not store")}, Options{
+		Logger:             zap.New(core),
+		LogShutdownSummary: true,
+	})
+	s.batcher = newWindowBatcher(time.Hour, 0, s.flushWindow, s.metrics.SpansPending[pendingQueueBatchWindow], time.Now)
+
+	pushSpans(t, s, "00000001")
+	s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Name: "error"}))
+	require.NoError(t, s.shutdown(context.Background()))
+	entries := logs.FilterMessage("Span writer exporter summary").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, map[string]interface{}{
+		"written":  int64(1),
+		"failed":   int64(1),
+		"dropped":  int64(0),
+		"filtered": int64(0),
+	}, entries[0].ContextMap())
+}
+
+func TestStore_noShutdownSummaryByDefault(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	s := newStorage(spanWriter{}, Options{Logger: zap.New(core)})
+	pushSpans(t, s, "00000001")
+	require.NoError(t, s.shutdown(context.Background()))
+	assert.Zero(t, logs.Len())
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/uber/jaeger-lib/metrics"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+// otherTagKeys is the label of tags not listed in Options.TagValueLengthKeys.
+const otherTagKeys = "other"
+
+// tagValueLengthBuckets are upper bounds of tag value lengths in bytes.
+var tagValueLengthBuckets = []float64{16, 64, 256, 1024, 4096, 16384, 65536}
+
+// tagValueLengths records lengths of string span tag values.
+type tagValueLengths struct {
+	byKey map[string]metrics.Histogram
+	other metrics.Histogram
+}
+
+func newTagValueLengths(factory metrics.Factory, keys []string) *tagValueLengths {
+	newHistogram := func(key string) metrics.Histogram {
+		return factory.Histogram(metrics.HistogramOptions{
+			Name:    "tag_value.length",
+			Tags:    map[string]string{"key": key},
+			Help:    "Length of string span tag values in bytes",
+			Buckets: tagValueLengthBuckets,
+		})
+	}
+	t := &tagValueLengths{
+		byKey: make(map[string]metrics.Histogram, len(keys)),
+		other: newHistogram(otherTagKeys),
+	}
+	for _, k := range keys {
+		t.byKey[k] = newHistogram(k)
+	}
+	return t
+}
+
+func (t *tagValueLengths) record(span *model.Span) {
+	for i := range span.Tags {
+		tag := &span.Tags[i]
+		if tag.VType != model.StringType {
+			continue
+		}
+		h, ok := t.byKey[tag.Key]
+		if !ok {
+			h = t.other
+		}
+		h.Record(float64(len(tag.VStr)))
+	}
+}
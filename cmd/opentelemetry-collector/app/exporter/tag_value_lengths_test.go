@@ -0,0 +1,94 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	commonv1 "github.com/open-telemetry/opentelemetry-proto/gen/go/common/v1"
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics"
+)
+
+// histogramRecorder is a metrics.Factory keeping histogram observations by key label.
+type histogramRecorder struct {
+	metrics.Factory
+	mux          sync.Mutex
+	observations map[string][]float64
+}
+
+func newHistogramRecorder() *histogramRecorder {
+	return &histogramRecorder{Factory: metrics.NullFactory, observations: map[string][]float64{}}
+}
+
+func (f *histogramRecorder) Namespace(metrics.NSOptions) metrics.Factory {
+	return f
+}
+
+func (f *histogramRecorder) Histogram(opts metrics.HistogramOptions) metrics.Histogram {
+	return recordedHistogram{recorder: f, key: opts.Tags["key"]}
+}
+
+type recordedHistogram struct {
+	recorder *histogramRecorder
+	key      string
+}
+
+func (h recordedHistogram) Record(v float64) {
+	h.recorder.mux.Lock()
+	defer h.recorder.mux.Unlock()
+	h.recorder.observations[h.key] = append(h.recorder.observations[h.key], v)
+}
+
+func TestStore_tagValueLengths(t *testing.T) {
+	recorder := newHistogramRecorder()
+	s := newStorage(spanWriter{}, Options{
+		RecordTagValueLengths: true,
+		TagValueLengthKeys:    []string{"http.url"},
+		MetricsFactory:        recorder,
+	})
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{
+		TraceId: testTraceID,
+		SpanId:  testSpanID,
+		Attributes: []*commonv1.AttributeKeyValue{
+			stringAttr("http.url", strings.Repeat("u", 300)),
+			stringAttr("db.statement", strings.Repeat("s", 5000)),
+			stringAttr("peer.service", "db"),
+			{Key: "http.status_code", Type: commonv1.AttributeKeyValue_INT, IntValue: 200},
+		},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]float64{
+		"http.url": {300},
+		"other":    {5000, 2},
+	}, recorder.observations)
+}
+
+func TestStore_tagValueLengthsDisabled(t *testing.T) {
+	recorder := newHistogramRecorder()
+	s := newStorage(spanWriter{}, Options{MetricsFactory: recorder})
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{
+		TraceId:    testTraceID,
+		SpanId:     testSpanID,
+		Attributes: []*commonv1.AttributeKeyValue{stringAttr("http.url", "http://localhost")},
+	}))
+	require.NoError(t, err)
+	assert.Empty(t, recorder.observations)
+}
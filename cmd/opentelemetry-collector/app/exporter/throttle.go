@@ -0,0 +1,82 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"time"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+// ThrottleMode controls handling of spans exceeding the global write rate.
+type ThrottleMode string
+
+const (
+	// ThrottleShed drops spans exceeding the rate.
+	ThrottleShed ThrottleMode = ""
+	// ThrottleBlock delays pushes until the rate allows writing all of their spans.
+	ThrottleBlock ThrottleMode = "block"
+)
+
+// writeThrottler limits the rate of spans written by all pushes.
+type writeThrottler struct {
+	limiter *rateLimiter
+	block   bool
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// newWriteThrottler allows spansPerSecond spans with bursts of burst spans, at least one span.
+// Zero burst defaults to spansPerSecond.
+func newWriteThrottler(spansPerSecond, burst float64, mode ThrottleMode, timeNow func() time.Time) *writeThrottler {
+	if burst <= 0 {
+		burst = spansPerSecond
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &writeThrottler{
+		limiter: newRateLimiter(spansPerSecond, burst, timeNow),
+		block:   mode == ThrottleBlock,
+		sleep:   sleepContext,
+	}
+}
+
+// admit returns the spans which can be written and the number of shed spans.
+// In block mode it waits until all spans can be written or the context is done.
+func (t *writeThrottler) admit(ctx context.Context, spans []*model.Span) ([]*model.Span, int, error) {
+	allowed := t.limiter.takeCredits(len(spans))
+	if !t.block {
+		return spans[:allowed], len(spans) - allowed, nil
+	}
+	for allowed < len(spans) {
+		if err := t.sleep(ctx, t.limiter.timeUntilCredit()); err != nil {
+			return nil, 0, err
+		}
+		allowed += t.limiter.takeCredits(len(spans) - allowed)
+	}
+	return spans, 0, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
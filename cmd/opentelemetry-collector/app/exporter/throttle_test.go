@@ -0,0 +1,110 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics/metricstest"
+	"go.opentelemetry.io/collector/consumer/pdata"
+)
+
+func tracesWithSpanCount(count int) pdata.Traces {
+	spans := make([]*tracev1.Span, count)
+	for i := range spans {
+		spans[i] = &tracev1.Span{TraceId: testTraceID, SpanId: []byte(fmt.Sprintf("%08d", i+1))}
+	}
+	return tracesWithSpans(spans...)
+}
+
+func TestStore_throttleShedsSpans(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	writer := &recordingWriter{}
+	metricsFactory := metricstest.NewFactory(0)
+	s := newStorage(writer, Options{MetricsFactory: metricsFactory})
+	s.throttler = newWriteThrottler(10, 10, ThrottleShed, clock.timeNow)
+
+	for i := 0; i < 3; i++ {
+		dropped, err := s.traceDataPusher(context.Background(), tracesWithSpanCount(20))
+		require.NoError(t, err)
+		assert.Equal(t, 10, dropped)
+		clock.advance(time.Second)
+	}
+	clock.advance(500 * time.Millisecond)
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpanCount(20))
+	require.NoError(t, err)
+	assert.Equal(t, 10, dropped, "balance is capped by burst")
+
+	assert.Len(t, writer.written(), 40)
+	metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{
+		Name:  "jaeger_exporter.spans.dropped",
+		Tags:  map[string]string{"reason": dropReasonGlobalRateLimited},
+		Value: 40,
+	})
+}
+
+func TestStore_throttleDefaultBurst(t *testing.T) {
+	writer := &recordingWriter{}
+	s := newStorage(writer, Options{MaxSpansPerSecond: 100})
+
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpanCount(50))
+	require.NoError(t, err)
+	assert.Equal(t, 0, dropped, "burst defaults to the rate")
+	assert.Len(t, writer.written(), 50)
+}
+
+func TestStore_throttleBlocks(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	writer := &recordingWriter{}
+	s := newStorage(writer, Options{})
+	s.throttler = newWriteThrottler(10, 5, ThrottleBlock, clock.timeNow)
+	var slept time.Duration
+	s.throttler.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		clock.advance(d)
+		return nil
+	}
+
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpanCount(20))
+	require.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	assert.Len(t, writer.written(), 20)
+	assert.Equal(t, 1500*time.Millisecond, slept)
+}
+
+func TestStore_throttleBlockCancelled(t *testing.T) {
+	writer := &recordingWriter{}
+	metricsFactory := metricstest.NewFactory(0)
+	s := newStorage(writer, Options{MetricsFactory: metricsFactory})
+	s.throttler = newWriteThrottler(1, 1, ThrottleBlock, time.Now)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dropped, err := s.traceDataPusher(ctx, tracesWithSpanCount(3))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 3, dropped)
+	assert.Empty(t, writer.written())
+	metricsFactory.AssertCounterMetrics(t, metricstest.ExpectedMetric{
+		Name:  "jaeger_exporter.spans.dropped",
+		Tags:  map[string]string{"reason": dropReasonContextCancelled},
+		Value: 3,
+	})
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/uber/jaeger-lib/metrics"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+// Names sanitized by the converter, reported in metrics.
+const (
+	sanitizedNameOperation = "operation"
+	sanitizedNameService   = "service"
+)
+
+// nameSanitizer replaces invalid UTF-8 in operation and service names, which breaks JSON serialization.
+type nameSanitizer struct {
+	operations metrics.Counter
+	services   metrics.Counter
+}
+
+func newNameSanitizer(factory metrics.Factory) *nameSanitizer {
+	counter := func(name string) metrics.Counter {
+		return factory.Counter(metrics.Options{Name: "names.sanitized", Tags: map[string]string{"name": name}})
+	}
+	return &nameSanitizer{
+		operations: counter(sanitizedNameOperation),
+		services:   counter(sanitizedNameService),
+	}
+}
+
+func (s *nameSanitizer) sanitizeService(process *model.Process) {
+	if process != nil && !utf8.ValidString(process.ServiceName) {
+		process.ServiceName = strings.ToValidUTF8(process.ServiceName, string(utf8.RuneError))
+		s.services.Inc(1)
+	}
+}
+
+func (s *nameSanitizer) sanitizeOperation(span *model.Span) {
+	if !utf8.ValidString(span.OperationName) {
+		span.OperationName = strings.ToValidUTF8(span.OperationName, string(utf8.RuneError))
+		s.operations.Inc(1)
+	}
+}
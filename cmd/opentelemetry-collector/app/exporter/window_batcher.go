@@ -0,0 +1,203 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/uber/jaeger-lib/metrics"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+// windowBatcher accumulates spans across pushes and flushes them
+// once the window interval elapses or the window reaches maxSpans, whichever comes first.
+type windowBatcher struct {
+	interval  time.Duration
+	maxSpans  int
+	flush     func(spans []*model.Span) error
+	pending   metrics.Gauge
+	timeNow   func() time.Time
+	afterFunc func(d time.Duration, f func()) windowTimer
+
+	mux         sync.Mutex
+	spans       []*model.Span
+	windowStart time.Time
+	result      *windowResult
+	timer       windowTimer
+	// window counts flushed windows, so that timers of flushed windows are ignored.
+	window  uint64
+	started bool
+	closed  bool
+
+	// timerFlushes tracks flushes triggered by timers, which close waits for.
+	timerFlushes sync.WaitGroup
+	closeOnce    sync.Once
+}
+
+// windowTimer is a timer flushing a window, i.e. *time.Timer.
+type windowTimer interface {
+	Stop() bool
+}
+
+// windowResult signals that the spans of a window were written.
+type windowResult struct {
+	done chan struct{}
+	err  error
+}
+
+// wait blocks until the window is written and returns the write error, or until the context is done.
+func (r *windowResult) wait(ctx context.Context) error {
+	select {
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newWindowBatcher(interval time.Duration, maxSpans int, flush func(spans []*model.Span) error, pending metrics.Gauge, timeNow func() time.Time) *windowBatcher {
+	return &windowBatcher{
+		interval: interval,
+		maxSpans: maxSpans,
+		flush:    flush,
+		pending:  pending,
+		timeNow:  timeNow,
+		afterFunc: func(d time.Duration, f func()) windowTimer {
+			return time.AfterFunc(d, f)
+		},
+	}
+}
+
+// start enables flushing each window by a timer armed when the window starts.
+func (b *windowBatcher) start() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.started = true
+	if len(b.spans) > 0 {
+		b.armTimer(b.interval - b.timeNow().Sub(b.windowStart))
+	}
+}
+
+// add appends spans to the current window and flushes it if it is full or due.
+// It returns the result of the window, nil if there are no spans.
+func (b *windowBatcher) add(spans []*model.Span) *windowResult {
+	if len(spans) == 0 {
+		return nil
+	}
+	b.mux.Lock()
+	if len(b.spans) == 0 {
+		b.windowStart = b.timeNow()
+		b.result = &windowResult{done: make(chan struct{})}
+		b.armTimer(b.interval)
+	}
+	b.spans = append(b.spans, spans...)
+	b.pending.Update(int64(len(b.spans)))
+	result := b.result
+	var pending []*model.Span
+	var pendingResult *windowResult
+	if b.isDue() {
+		pending, pendingResult = b.takeSpans()
+	}
+	b.mux.Unlock()
+	b.flushSpans(pending, pendingResult)
+	return result
+}
+
+// flushIfDue flushes the current window if its interval elapsed.
+func (b *windowBatcher) flushIfDue() {
+	b.mux.Lock()
+	var pending []*model.Span
+	var pendingResult *windowResult
+	if len(b.spans) > 0 && b.isDue() {
+		pending, pendingResult = b.takeSpans()
+	}
+	b.mux.Unlock()
+	b.flushSpans(pending, pendingResult)
+}
+
+// onTimer flushes the window when its timer fires,
+// re-arming the timer if the window is not due yet.
+func (b *windowBatcher) onTimer(window uint64) {
+	b.mux.Lock()
+	if b.closed || window != b.window {
+		b.mux.Unlock()
+		return
+	}
+	b.timer = nil
+	var pending []*model.Span
+	var pendingResult *windowResult
+	if len(b.spans) > 0 {
+		if b.isDue() {
+			pending, pendingResult = b.takeSpans()
+		} else {
+			b.armTimer(b.interval - b.timeNow().Sub(b.windowStart))
+		}
+	}
+	b.timerFlushes.Add(1)
+	b.mux.Unlock()
+	defer b.timerFlushes.Done()
+	b.flushSpans(pending, pendingResult)
+}
+
+// close stops timers, flushes the pending window and waits for flushes triggered by timers.
+func (b *windowBatcher) close() {
+	b.closeOnce.Do(func() {
+		b.mux.Lock()
+		b.closed = true
+		pending, pendingResult := b.takeSpans()
+		b.mux.Unlock()
+		b.flushSpans(pending, pendingResult)
+		b.timerFlushes.Wait()
+	})
+}
+
+// armTimer must be called with the mutex held.
+func (b *windowBatcher) armTimer(d time.Duration) {
+	if b.started && !b.closed {
+		window := b.window
+		b.timer = b.afterFunc(d, func() { b.onTimer(window) })
+	}
+}
+
+// isDue must be called with the mutex held.
+func (b *windowBatcher) isDue() bool {
+	if b.maxSpans > 0 && len(b.spans) >= b.maxSpans {
+		return true
+	}
+	return b.timeNow().Sub(b.windowStart) >= b.interval
+}
+
+// takeSpans must be called with the mutex held.
+func (b *windowBatcher) takeSpans() ([]*model.Span, *windowResult) {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	spans, result := b.spans, b.result
+	b.spans, b.result = nil, nil
+	b.pending.Update(0)
+	b.window++
+	return spans, result
+}
+
+func (b *windowBatcher) flushSpans(spans []*model.Span, result *windowResult) {
+	if len(spans) > 0 {
+		result.err = b.flush(spans)
+		close(result.done)
+	}
+}
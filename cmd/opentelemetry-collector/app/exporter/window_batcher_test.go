@@ -0,0 +1,263 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics/metricstest"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) timeNow() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newWindowBatchingStorage(interval time.Duration, maxSpans int, clock *fakeClock) (*storage, *recordingWriter) {
+	writer := &recordingWriter{}
+	s := newStorage(writer, Options{})
+	s.batcher = newWindowBatcher(interval, maxSpans, s.flushWindow, s.metrics.SpansPending[pendingQueueBatchWindow], clock.timeNow)
+	return s, writer
+}
+
+func pushSpans(t *testing.T, s *storage, spanIDs ...string) {
+	spans := make([]*tracev1.Span, 0, len(spanIDs))
+	for _, id := range spanIDs {
+		spans = append(spans, &tracev1.Span{TraceId: testTraceID, SpanId: []byte(id)})
+	}
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpans(spans...))
+	require.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+}
+
+func TestWindowBatcher_timeTriggeredFlush(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s, writer := newWindowBatchingStorage(time.Second, 100, clock)
+
+	pushSpans(t, s, "00000001")
+	clock.advance(500 * time.Millisecond)
+	pushSpans(t, s, "00000002")
+	s.batcher.flushIfDue()
+	assert.Empty(t, writer.written())
+
+	clock.advance(500 * time.Millisecond)
+	s.batcher.flushIfDue()
+	assert.Len(t, writer.written(), 2)
+
+	clock.advance(10 * time.Second)
+	pushSpans(t, s, "00000003")
+	assert.Len(t, writer.written(), 2, "new window starts with its first span")
+}
+
+func TestWindowBatcher_sizeTriggeredFlush(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s, writer := newWindowBatchingStorage(time.Minute, 3, clock)
+
+	pushSpans(t, s, "00000001", "00000002")
+	assert.Empty(t, writer.written())
+	pushSpans(t, s, "00000003", "00000004")
+	assert.Len(t, writer.written(), 4)
+	pushSpans(t, s, "00000005")
+	assert.Len(t, writer.written(), 4)
+}
+
+func TestWindowBatcher_pendingGauge(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	metricsFactory := metricstest.NewFactory(0)
+	s := newStorage(&recordingWriter{}, Options{MetricsFactory: metricsFactory})
+	s.batcher = newWindowBatcher(time.Minute, 3, s.flushWindow, s.metrics.SpansPending[pendingQueueBatchWindow], clock.timeNow)
+	pending := func(value int) metricstest.ExpectedMetric {
+		return metricstest.ExpectedMetric{Name: "jaeger_exporter.spans.pending", Tags: map[string]string{"queue": "batch_window"}, Value: value}
+	}
+
+	pushSpans(t, s, "00000001", "00000002")
+	metricsFactory.AssertGaugeMetrics(t, pending(2))
+	pushSpans(t, s, "00000003")
+	metricsFactory.AssertGaugeMetrics(t, pending(0))
+	pushSpans(t, s, "00000004")
+	metricsFactory.AssertGaugeMetrics(t, pending(1))
+}
+
+func TestWindowBatcher_shutdownFlushesPendingWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	s, writer := newWindowBatchingStorage(time.Minute, 0, clock)
+	s.batcher.start()
+
+	pushSpans(t, s, "00000001")
+	assert.Empty(t, writer.written())
+	require.NoError(t, s.shutdown(context.Background()))
+	written := writer.written()
+	require.Len(t, written, 1)
+	assert.Equal(t, model.SpanID(0x3030303030303031), written[0].SpanID)
+}
+
+// fakeTimers schedules window timers on a fake clock.
+type fakeTimers struct {
+	clock  *fakeClock
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	at      time.Time
+	f       func()
+	stopped bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	stopped := !t.stopped
+	t.stopped = true
+	return stopped
+}
+
+func (ft *fakeTimers) afterFunc(d time.Duration, f func()) windowTimer {
+	timer := &fakeTimer{at: ft.clock.now.Add(d), f: f}
+	ft.timers = append(ft.timers, timer)
+	return timer
+}
+
+// fireDue runs timers which are due and not stopped.
+func (ft *fakeTimers) fireDue() {
+	timers := ft.timers
+	ft.timers = nil
+	for _, timer := range timers {
+		if timer.stopped {
+			continue
+		}
+		if timer.at.After(ft.clock.now) {
+			ft.timers = append(ft.timers, timer)
+			continue
+		}
+		timer.stopped = true
+		timer.f()
+	}
+}
+
+func TestWindowBatcher_timerArmedWhenWindowStarts(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	timers := &fakeTimers{clock: clock}
+	s, writer := newWindowBatchingStorage(time.Second, 0, clock)
+	s.batcher.afterFunc = timers.afterFunc
+	s.batcher.start()
+	assert.Empty(t, timers.timers, "no timer without a window")
+
+	// the window opens just after a tick of a fixed period ticker would have fired
+	clock.advance(10 * time.Millisecond)
+	pushSpans(t, s, "00000001")
+	require.Len(t, timers.timers, 1)
+	assert.Equal(t, time.Unix(0, 0).Add(1010*time.Millisecond), timers.timers[0].at)
+
+	clock.advance(990 * time.Millisecond)
+	timers.fireDue()
+	assert.Empty(t, writer.written())
+
+	clock.advance(10 * time.Millisecond)
+	timers.fireDue()
+	assert.Len(t, writer.written(), 1, "window is flushed one interval after its first span")
+	assert.Empty(t, timers.timers)
+}
+
+func TestWindowBatcher_sizeTriggeredFlushStopsTimer(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	timers := &fakeTimers{clock: clock}
+	s, writer := newWindowBatchingStorage(time.Second, 2, clock)
+	s.batcher.afterFunc = timers.afterFunc
+	s.batcher.start()
+
+	pushSpans(t, s, "00000001", "00000002")
+	assert.Len(t, writer.written(), 2)
+	clock.advance(500 * time.Millisecond)
+	pushSpans(t, s, "00000003")
+	require.Len(t, timers.timers, 2)
+	assert.True(t, timers.timers[0].stopped)
+
+	clock.advance(500 * time.Millisecond)
+	timers.fireDue()
+	assert.Len(t, writer.written(), 2, "timer of the flushed window is stopped")
+	clock.advance(500 * time.Millisecond)
+	timers.fireDue()
+	assert.Len(t, writer.written(), 3)
+}
+
+func TestWindowBatcher_staleTimerIgnored(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	timers := &fakeTimers{clock: clock}
+	s, writer := newWindowBatchingStorage(time.Second, 0, clock)
+	s.batcher.afterFunc = timers.afterFunc
+	s.batcher.start()
+
+	pushSpans(t, s, "00000001")
+	stale := timers.timers[0]
+	clock.advance(time.Second)
+	s.batcher.flushIfDue()
+	assert.Len(t, writer.written(), 1)
+
+	pushSpans(t, s, "00000002")
+	// the timer of the first window fired concurrently with the flush
+	stale.f()
+	assert.Len(t, writer.written(), 1)
+	clock.advance(time.Second)
+	timers.fireDue()
+	assert.Len(t, writer.written(), 2)
+}
+
+func TestWindowBatcher_periodicFlush(t *testing.T) {
+	writer := &recordingWriter{}
+	s := newStorage(writer, Options{BatchWindow: time.Millisecond})
+	s.batcher.start()
+	defer s.shutdown(context.Background())
+
+	pushSpans(t, s, "00000001")
+	assert.Eventually(t, func() bool {
+		return len(writer.written()) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestWindowBatcher_debugTracer(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	tracer := mocktracer.New()
+	writer := &recordingWriter{}
+	s := newStorage(writer, Options{DebugTracer: tracer})
+	s.batcher = newWindowBatcher(time.Second, 0, s.flushWindow, s.metrics.SpansPending[pendingQueueBatchWindow], clock.timeNow)
+
+	pushSpans(t, s, "00000001", "00000002")
+	clock.advance(time.Second)
+	s.batcher.flushIfDue()
+	require.Len(t, writer.written(), 2)
+
+	finished := tracer.FinishedSpans()
+	require.Len(t, finished, 3)
+	assert.Equal(t, debugOperationEnqueue, finished[0].OperationName)
+	assert.Equal(t, debugOperationWriteSpans, finished[1].OperationName)
+	assert.Equal(t, debugOperationFlush, finished[2].OperationName)
+	for _, span := range finished {
+		assert.Equal(t, 2, span.Tag("spans"))
+		assert.Nil(t, span.Tag("error"))
+	}
+}
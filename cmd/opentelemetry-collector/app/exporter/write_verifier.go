@@ -0,0 +1,73 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/uber/jaeger-lib/metrics"
+	"go.uber.org/zap"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+)
+
+// writeVerifier reads back written spans matching a predicate to confirm they were persisted.
+type writeVerifier struct {
+	reader    spanstore.Reader
+	predicate func(span *model.Span) bool
+	logger    *zap.Logger
+	verified  metrics.Counter
+	failed    metrics.Counter
+}
+
+func newWriteVerifier(reader spanstore.Reader, predicate func(span *model.Span) bool, factory metrics.Factory, logger *zap.Logger) *writeVerifier {
+	return &writeVerifier{
+		reader:    reader,
+		predicate: predicate,
+		logger:    logger,
+		verified:  factory.Counter(metrics.Options{Name: "spans.verifications", Tags: map[string]string{"result": "ok"}}),
+		failed:    factory.Counter(metrics.Options{Name: "spans.verifications", Tags: map[string]string{"result": "failed"}}),
+	}
+}
+
+// verify checks that a written span can be read back if it matches the predicate.
+func (v *writeVerifier) verify(ctx context.Context, span *model.Span) {
+	if !v.predicate(span) {
+		return
+	}
+	trace, err := v.reader.GetTrace(ctx, span.TraceID)
+	if err == nil && containsSpan(trace, span.SpanID) {
+		v.verified.Inc(1)
+		return
+	}
+	v.failed.Inc(1)
+	v.logger.Warn("Written span could not be read back",
+		zap.Stringer("trace_id", span.TraceID),
+		zap.Stringer("span_id", span.SpanID),
+		zap.Error(err))
+}
+
+func containsSpan(trace *model.Trace, spanID model.SpanID) bool {
+	if trace == nil {
+		return false
+	}
+	for _, s := range trace.Spans {
+		if s.SpanID == spanID {
+			return true
+		}
+	}
+	return false
+}
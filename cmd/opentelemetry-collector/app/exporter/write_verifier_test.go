@@ -0,0 +1,154 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tracev1 "github.com/open-telemetry/opentelemetry-proto/gen/go/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics/metricstest"
+	"go.opentelemetry.io/collector/config/configmodels"
+	"go.uber.org/zap"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+	spanStoreMocks "github.com/jaegertracing/jaeger/storage/spanstore/mocks"
+)
+
+func TestStore_verifyWrites(t *testing.T) {
+	traceID := model.NewTraceID(0x3031323334353637, 0x3839616263646566)
+	critical := tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Name: "critical"})
+	tests := []struct {
+		caption  string
+		trace    *model.Trace
+		err      error
+		data     []*tracev1.Span
+		verified int
+		failed   int
+	}{
+		{
+			caption:  "span is persisted",
+			trace:    &model.Trace{Spans: []*model.Span{{TraceID: traceID, SpanID: model.SpanID(0x3031323334353637)}}},
+			verified: 1,
+		},
+		{
+			caption: "span is missing in trace",
+			trace:   &model.Trace{Spans: []*model.Span{{TraceID: traceID, SpanID: model.SpanID(1)}}},
+			failed:  1,
+		},
+		{
+			caption: "trace not found",
+			err:     spanstore.ErrTraceNotFound,
+			failed:  1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			reader := &spanStoreMocks.Reader{}
+			reader.On("GetTrace", mock.Anything, traceID).Return(test.trace, test.err)
+			metricsFactory := metricstest.NewFactory(0)
+			s := newStorage(spanWriter{}, Options{})
+			s.verifier = newWriteVerifier(reader, isCritical, metricsFactory, zap.NewNop())
+
+			dropped, err := s.traceDataPusher(context.Background(), critical)
+			require.NoError(t, err)
+			assert.Equal(t, 0, dropped)
+			metricsFactory.AssertCounterMetrics(t,
+				metricstest.ExpectedMetric{Name: "spans.verifications", Tags: map[string]string{"result": "ok"}, Value: test.verified},
+				metricstest.ExpectedMetric{Name: "spans.verifications", Tags: map[string]string{"result": "failed"}, Value: test.failed},
+			)
+		})
+	}
+}
+
+func TestStore_verifyWritesSkipsUnmatchedAndFailedSpans(t *testing.T) {
+	reader := &spanStoreMocks.Reader{}
+	s := newStorage(spanWriter{err: errors.New("could not store")}, Options{})
+	s.verifier = newWriteVerifier(reader, isCritical, metricstest.NewFactory(0), zap.NewNop())
+
+	dropped, err := s.traceDataPusher(context.Background(), tracesWithSpans(
+		&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Name: "other"},
+		// spanWriter fails spans named "error", they are never verified
+		&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Name: "error"},
+	))
+	require.Error(t, err)
+	assert.Equal(t, 1, dropped)
+	reader.AssertNotCalled(t, "GetTrace", mock.Anything, mock.Anything)
+}
+
+// persistedSpansReader reads back spans persisted by a bufferingWriter.
+type persistedSpansReader struct {
+	spanstore.Reader
+	writer *bufferingWriter
+}
+
+func (r persistedSpansReader) GetTrace(_ context.Context, traceID model.TraceID) (*model.Trace, error) {
+	r.writer.mux.Lock()
+	defer r.writer.mux.Unlock()
+	trace := &model.Trace{}
+	for _, span := range r.writer.persisted {
+		if span.TraceID == traceID {
+			trace.Spans = append(trace.Spans, span)
+		}
+	}
+	return trace, nil
+}
+
+func TestStore_verifyWritesAfterFlush(t *testing.T) {
+	writer := &bufferingWriter{}
+	metricsFactory := metricstest.NewFactory(0)
+	s := newStorage(writer, Options{DurableWrites: true})
+	s.verifier = newWriteVerifier(persistedSpansReader{writer: writer}, isCritical, metricsFactory, zap.NewNop())
+
+	_, err := s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Name: "critical"}))
+	require.NoError(t, err)
+	metricsFactory.AssertCounterMetrics(t,
+		metricstest.ExpectedMetric{Name: "spans.verifications", Tags: map[string]string{"result": "ok"}, Value: 1},
+		metricstest.ExpectedMetric{Name: "spans.verifications", Tags: map[string]string{"result": "failed"}, Value: 0},
+	)
+
+	writer.flushErr = errors.New("could not flush")
+	_, err = s.traceDataPusher(context.Background(), tracesWithSpans(&tracev1.Span{TraceId: testTraceID, SpanId: testSpanID, Name: "critical"}))
+	require.Error(t, err)
+	metricsFactory.AssertCounterMetrics(t,
+		metricstest.ExpectedMetric{Name: "spans.verifications", Tags: map[string]string{"result": "ok"}, Value: 1},
+		metricstest.ExpectedMetric{Name: "spans.verifications", Tags: map[string]string{"result": "failed"}, Value: 0},
+	)
+}
+
+func TestNew_verifyWritesReaderError(t *testing.T) {
+	exporter, err := NewSpanWriterExporter(&configmodels.ExporterSettings{},
+		mockStorageFactory{spanWriter: spanWriter{}, readerErr: errors.New("no reader")},
+		Options{VerifySpan: isCritical})
+	require.Nil(t, exporter)
+	assert.EqualError(t, err, "no reader")
+}
+
+func TestNew_verifyWritesNilReader(t *testing.T) {
+	exporter, err := NewSpanWriterExporter(&configmodels.ExporterSettings{},
+		mockStorageFactory{spanWriter: spanWriter{}},
+		Options{VerifySpan: isCritical})
+	require.Nil(t, exporter)
+	assert.Equal(t, errNilSpanReader, err)
+}
+
+func isCritical(span *model.Span) bool {
+	return span.OperationName == "critical" || span.OperationName == "error"
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/elasticsearch"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/grpcplugin"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/kafka"
+	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/exporter/objectstore"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/processor/resourceprocessor"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/receiver/jaegerreceiver"
 	"github.com/jaegertracing/jaeger/cmd/opentelemetry-collector/app/receiver/zipkinreceiver"
@@ -52,6 +53,8 @@ func AddStorageFlags(storage string) (func(*flag.FlagSet), error) {
 			flagFn = append(flagFn, kafka.DefaultOptions().AddFlags)
 		case "grpc-plugin":
 			flagFn = append(flagFn, grpcplugin.DefaultOptions().AddFlags)
+		case "objectstore":
+			flagFn = append(flagFn, objectstore.DefaultOptions().AddFlags)
 		default:
 			return nil, fmt.Errorf("unknown storage type: %s", s)
 		}
@@ -91,7 +91,7 @@ func (f *Factory) CreateSpanReader() (spanstore.Reader, error) {
 
 // CreateSpanWriter implements storage.Factory
 func (f *Factory) CreateSpanWriter() (spanstore.Writer, error) {
-	return NewSpanWriter(f.producer, f.marshaller, f.options.Topic, f.metricsFactory, f.logger), nil
+	return NewSpanWriter(f.producer, f.marshaller, f.options.Topic, f.metricsFactory, f.logger, PartitionByTraceID(f.options.PartitionByTraceID)), nil
 }
 
 // CreateDependencyReader implements storage.Factory
@@ -46,6 +46,7 @@ const (
 	suffixBatchLinger      = ".batch-linger"
 	suffixBatchSize        = ".batch-size"
 	suffixBatchMaxMessages = ".batch-max-messages"
+	suffixPartitionByTrace = ".partition-by-trace-id"
 
 	defaultBroker           = "127.0.0.1:9092"
 	defaultTopic            = "jaeger-spans"
@@ -56,6 +57,7 @@ const (
 	defaultBatchLinger      = 0
 	defaultBatchSize        = 0
 	defaultBatchMaxMessages = 0
+	defaultPartitionByTrace = true
 )
 
 var (
@@ -111,6 +113,8 @@ type Options struct {
 	Config   producer.Configuration `mapstructure:",squash"`
 	Topic    string                 `mapstructure:"topic"`
 	Encoding string                 `mapstructure:"encoding"`
+	// PartitionByTraceID keys messages by trace ID so that all spans of a trace land on one partition.
+	PartitionByTraceID bool `mapstructure:"partition_by_trace_id"`
 }
 
 // AddFlags adds flags for Options
@@ -162,6 +166,11 @@ func (opt *Options) AddFlags(flagSet *flag.FlagSet) {
 		defaultBatchMaxMessages,
 		"(experimental) Number of message to batch before sending records to Kafka. Higher value reduce request to Kafka but increase latency and the possibility of data loss in case of process restart. See https://kafka.apache.org/documentation/",
 	)
+	flagSet.Bool(
+		configPrefix+suffixPartitionByTrace,
+		defaultPartitionByTrace,
+		"Use trace ID as the message key so that all spans of a trace are sent to the same partition. If disabled, messages are not keyed and are spread across partitions",
+	)
 	auth.AddFlags(configPrefix, flagSet)
 }
 
@@ -199,6 +208,7 @@ func (opt *Options) InitFromViper(v *viper.Viper) {
 	}
 	opt.Topic = v.GetString(configPrefix + suffixTopic)
 	opt.Encoding = v.GetString(configPrefix + suffixEncoding)
+	opt.PartitionByTraceID = v.GetBool(configPrefix + suffixPartitionByTrace)
 }
 
 // stripWhiteSpace removes all whitespace characters from a string
@@ -41,6 +41,7 @@ func TestOptionsWithFlags(t *testing.T) {
 		"--kafka.producer.batch-linger=1s",
 		"--kafka.producer.batch-size=128000",
 		"--kafka.producer.batch-max-messages=100",
+		"--kafka.producer.partition-by-trace-id=false",
 	})
 	opts.InitFromViper(v)
 
@@ -53,6 +54,7 @@ func TestOptionsWithFlags(t *testing.T) {
 	assert.Equal(t, 128000, opts.Config.BatchSize)
 	assert.Equal(t, time.Duration(1*time.Second), opts.Config.BatchLinger)
 	assert.Equal(t, 100, opts.Config.BatchMaxMessages)
+	assert.False(t, opts.PartitionByTraceID)
 }
 
 func TestFlagDefaults(t *testing.T) {
@@ -70,6 +72,7 @@ func TestFlagDefaults(t *testing.T) {
 	assert.Equal(t, 0, opts.Config.BatchSize)
 	assert.Equal(t, time.Duration(0*time.Second), opts.Config.BatchLinger)
 	assert.Equal(t, 0, opts.Config.BatchMaxMessages)
+	assert.True(t, opts.PartitionByTraceID)
 }
 
 func TestCompressionLevelDefaults(t *testing.T) {
@@ -33,6 +33,20 @@ type SpanWriter struct {
 	producer   sarama.AsyncProducer
 	marshaller Marshaller
 	topic      string
+	// unkeyed disables keying messages by trace ID
+	unkeyed bool
+}
+
+// WriterOption is a function that sets some option on the SpanWriter.
+type WriterOption func(w *SpanWriter)
+
+// PartitionByTraceID controls whether messages are keyed by trace ID.
+// Keyed messages of one trace are always sent to the same partition,
+// unkeyed messages are distributed across partitions by the producer's partitioner.
+func PartitionByTraceID(enabled bool) WriterOption {
+	return func(w *SpanWriter) {
+		w.unkeyed = !enabled
+	}
 }
 
 // NewSpanWriter initiates and returns a new kafka spanwriter
@@ -42,6 +56,7 @@ func NewSpanWriter(
 	topic string,
 	factory metrics.Factory,
 	logger *zap.Logger,
+	options ...WriterOption,
 ) *SpanWriter {
 	writeMetrics := spanWriterMetrics{
 		SpansWrittenSuccess: factory.Counter(metrics.Options{Name: "kafka_spans_written", Tags: map[string]string{"status": "success"}}),
@@ -60,12 +75,16 @@ func NewSpanWriter(
 		}
 	}()
 
-	return &SpanWriter{
+	w := &SpanWriter{
 		producer:   producer,
 		marshaller: marshaller,
 		topic:      topic,
 		metrics:    writeMetrics,
 	}
+	for _, option := range options {
+		option(w)
+	}
+	return w
 }
 
 // WriteSpan writes the span to kafka.
@@ -78,11 +97,14 @@ func (w *SpanWriter) WriteSpan(span *model.Span) error {
 
 	// The AsyncProducer accepts messages on a channel and produces them asynchronously
 	// in the background as efficiently as possible
-	w.producer.Input() <- &sarama.ProducerMessage{
+	msg := &sarama.ProducerMessage{
 		Topic: w.topic,
-		Key:   sarama.StringEncoder(span.TraceID.String()),
 		Value: sarama.ByteEncoder(spanBytes),
 	}
+	if !w.unkeyed {
+		msg.Key = sarama.StringEncoder(span.TraceID.String())
+	}
+	w.producer.Input() <- msg
 	return nil
 }
 
@@ -23,6 +23,7 @@ import (
 	saramaMocks "github.com/Shopify/sarama/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/uber/jaeger-lib/metrics/metricstest"
 	"go.uber.org/zap"
 
@@ -184,3 +185,63 @@ func TestMarshallerErr(t *testing.T) {
 			})
 	})
 }
+
+// capturingProducer is a sarama.AsyncProducer which keeps produced messages.
+type capturingProducer struct {
+	input     chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+}
+
+func newCapturingProducer() *capturingProducer {
+	return &capturingProducer{
+		input:     make(chan *sarama.ProducerMessage, 10),
+		successes: make(chan *sarama.ProducerMessage),
+		errors:    make(chan *sarama.ProducerError),
+	}
+}
+
+func (p *capturingProducer) AsyncClose() {}
+
+func (p *capturingProducer) Close() error {
+	close(p.successes)
+	close(p.errors)
+	return nil
+}
+
+func (p *capturingProducer) Input() chan<- *sarama.ProducerMessage { return p.input }
+
+func (p *capturingProducer) Successes() <-chan *sarama.ProducerMessage { return p.successes }
+
+func (p *capturingProducer) Errors() <-chan *sarama.ProducerError { return p.errors }
+
+func TestKafkaWriterPartitioning(t *testing.T) {
+	tests := []struct {
+		caption string
+		options []WriterOption
+		key     sarama.Encoder
+	}{
+		{caption: "default", key: sarama.StringEncoder(sampleSpan.TraceID.String())},
+		{caption: "by trace ID", options: []WriterOption{PartitionByTraceID(true)}, key: sarama.StringEncoder(sampleSpan.TraceID.String())},
+		{caption: "unkeyed", options: []WriterOption{PartitionByTraceID(false)}},
+	}
+	for _, test := range tests {
+		t.Run(test.caption, func(t *testing.T) {
+			producer := newCapturingProducer()
+			writer := NewSpanWriter(producer, newProtobufMarshaller(), "someTopic", metricstest.NewFactory(0), zap.NewNop(), test.options...)
+			defer writer.Close()
+
+			require.NoError(t, writer.WriteSpan(sampleSpan))
+			msg := <-producer.input
+			assert.Equal(t, "someTopic", msg.Topic)
+			assert.Equal(t, test.key, msg.Key)
+
+			value, err := msg.Value.Encode()
+			require.NoError(t, err)
+			span, err := NewProtobufUnmarshaller().Unmarshal(value)
+			require.NoError(t, err)
+			assert.Equal(t, sampleSpan.SpanID, span.SpanID)
+			assert.Equal(t, sampleSpan.TraceID, span.TraceID)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/spf13/viper"
+	"github.com/uber/jaeger-lib/metrics"
+	"go.uber.org/zap"
+
+	"github.com/jaegertracing/jaeger/storage/dependencystore"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+)
+
+// Factory implements storage.Factory and creates write-only storage components backed by an S3-compatible object store.
+type Factory struct {
+	options Options
+
+	logger *zap.Logger
+	store  ObjectStore
+}
+
+// NewFactory creates a new Factory.
+func NewFactory() *Factory {
+	return &Factory{}
+}
+
+// AddFlags implements plugin.Configurable
+func (f *Factory) AddFlags(flagSet *flag.FlagSet) {
+	f.options.AddFlags(flagSet)
+}
+
+// InitFromViper implements plugin.Configurable
+func (f *Factory) InitFromViper(v *viper.Viper) {
+	f.options.InitFromViper(v)
+}
+
+// InitFromOptions initializes factory from options.
+func (f *Factory) InitFromOptions(o Options) {
+	f.options = o
+}
+
+// Initialize implements storage.Factory
+func (f *Factory) Initialize(_ metrics.Factory, logger *zap.Logger) error {
+	f.logger = logger
+	logger.Info("Object store factory",
+		zap.String("endpoint", f.options.S3.Endpoint),
+		zap.String("bucket", f.options.S3.Bucket),
+		zap.String("key prefix", f.options.KeyPrefix))
+	store, err := NewS3Store(f.options.S3)
+	if err != nil {
+		return err
+	}
+	f.store = store
+	return nil
+}
+
+// CreateSpanReader implements storage.Factory
+func (f *Factory) CreateSpanReader() (spanstore.Reader, error) {
+	return nil, errors.New("object store storage is write-only")
+}
+
+// CreateSpanWriter implements storage.Factory
+func (f *Factory) CreateSpanWriter() (spanstore.Writer, error) {
+	return NewSpanWriter(f.store, f.logger, f.options.writerOptions()...), nil
+}
+
+// CreateDependencyReader implements storage.Factory
+func (f *Factory) CreateDependencyReader() (dependencystore.Reader, error) {
+	return nil, errors.New("object store storage is write-only")
+}
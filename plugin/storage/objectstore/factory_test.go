@@ -0,0 +1,68 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/jaeger-lib/metrics"
+	"go.uber.org/zap"
+
+	"github.com/jaegertracing/jaeger/pkg/config"
+	"github.com/jaegertracing/jaeger/storage"
+)
+
+// Checks that object store Factory conforms to storage.Factory API
+var _ storage.Factory = new(Factory)
+
+func TestObjectStoreFactory(t *testing.T) {
+	f := NewFactory()
+	v, command := config.Viperize(f.AddFlags)
+	command.ParseFlags([]string{})
+	f.InitFromViper(v)
+	assert.Equal(t, errBucketRequired, f.Initialize(metrics.NullFactory, zap.NewNop()))
+
+	command.ParseFlags([]string{"--object-store.bucket=traces"})
+	f.InitFromViper(v)
+	require.NoError(t, f.Initialize(metrics.NullFactory, zap.NewNop()))
+	assert.IsType(t, &S3Store{}, f.store)
+
+	writer, err := f.CreateSpanWriter()
+	require.NoError(t, err)
+	require.IsType(t, &SpanWriter{}, writer)
+	assert.NoError(t, writer.(*SpanWriter).Close())
+
+	_, err = f.CreateSpanReader()
+	assert.Error(t, err)
+
+	_, err = f.CreateDependencyReader()
+	assert.Error(t, err)
+}
+
+func TestObjectStoreFactory_initFromOptions(t *testing.T) {
+	f := NewFactory()
+	f.InitFromOptions(Options{S3: S3Config{Bucket: "traces"}, KeyPrefix: "jaeger", Gzip: true})
+	require.NoError(t, f.Initialize(metrics.NullFactory, zap.NewNop()))
+
+	writer, err := f.CreateSpanWriter()
+	require.NoError(t, err)
+	w := writer.(*SpanWriter)
+	defer w.Close()
+	assert.Equal(t, "jaeger", w.keyPrefix)
+	assert.True(t, w.gzip)
+	assert.Equal(t, defaultFlushInterval, w.flushInterval)
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore
+
+import (
+	"flag"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	configPrefix           = "object-store"
+	suffixEndpoint         = ".endpoint"
+	suffixBucket           = ".bucket"
+	suffixRegion           = ".region"
+	suffixForcePathStyle   = ".force-path-style"
+	suffixAccessKeyID      = ".access-key-id"
+	suffixSecretAccessKey  = ".secret-access-key"
+	suffixKeyPrefix        = ".key-prefix"
+	suffixFlushInterval    = ".flush-interval"
+	suffixMaxBufferedSpans = ".max-buffered-spans"
+	suffixGzip             = ".gzip"
+
+	defaultRegion         = "us-east-1"
+	defaultForcePathStyle = false
+	defaultGzip           = false
+)
+
+// Options stores the configuration options for the object store
+type Options struct {
+	S3               S3Config      `mapstructure:",squash"`
+	KeyPrefix        string        `mapstructure:"key_prefix"`
+	FlushInterval    time.Duration `mapstructure:"flush_interval"`
+	MaxBufferedSpans int           `mapstructure:"max_buffered_spans"`
+	Gzip             bool          `mapstructure:"gzip"`
+}
+
+// AddFlags adds flags for Options
+func (opt *Options) AddFlags(flagSet *flag.FlagSet) {
+	flagSet.String(
+		configPrefix+suffixEndpoint,
+		"",
+		"The endpoint of the S3-compatible object store, e.g. http://127.0.0.1:9000. Defaults to the AWS endpoint of the region")
+	flagSet.String(
+		configPrefix+suffixBucket,
+		"",
+		"The name of the bucket spans are uploaded to")
+	flagSet.String(
+		configPrefix+suffixRegion,
+		defaultRegion,
+		"The region of the bucket")
+	flagSet.Bool(
+		configPrefix+suffixForcePathStyle,
+		defaultForcePathStyle,
+		"Address the bucket in the path of requests instead of the host name, as required by most S3-compatible object stores")
+	flagSet.String(
+		configPrefix+suffixAccessKeyID,
+		"",
+		"The access key ID of static credentials. If empty, the default AWS credential chain is used")
+	flagSet.String(
+		configPrefix+suffixSecretAccessKey,
+		"",
+		"The secret access key of static credentials")
+	flagSet.String(
+		configPrefix+suffixKeyPrefix,
+		"",
+		"The prefix of object keys")
+	flagSet.Duration(
+		configPrefix+suffixFlushInterval,
+		defaultFlushInterval,
+		"How often buffered spans are uploaded")
+	flagSet.Int(
+		configPrefix+suffixMaxBufferedSpans,
+		defaultMaxBufferedSpans,
+		"The number of buffered spans which triggers an upload before the flush interval elapses")
+	flagSet.Bool(
+		configPrefix+suffixGzip,
+		defaultGzip,
+		"Compress uploaded objects with gzip")
+}
+
+// InitFromViper initializes Options with properties from viper
+func (opt *Options) InitFromViper(v *viper.Viper) {
+	opt.S3 = S3Config{
+		Endpoint:        v.GetString(configPrefix + suffixEndpoint),
+		Bucket:          v.GetString(configPrefix + suffixBucket),
+		Region:          v.GetString(configPrefix + suffixRegion),
+		ForcePathStyle:  v.GetBool(configPrefix + suffixForcePathStyle),
+		AccessKeyID:     v.GetString(configPrefix + suffixAccessKeyID),
+		SecretAccessKey: v.GetString(configPrefix + suffixSecretAccessKey),
+	}
+	opt.KeyPrefix = v.GetString(configPrefix + suffixKeyPrefix)
+	opt.FlushInterval = v.GetDuration(configPrefix + suffixFlushInterval)
+	opt.MaxBufferedSpans = v.GetInt(configPrefix + suffixMaxBufferedSpans)
+	opt.Gzip = v.GetBool(configPrefix + suffixGzip)
+}
+
+// writerOptions returns the SpanWriter options of the configuration.
+func (opt *Options) writerOptions() []WriterOption {
+	options := []WriterOption{KeyPrefix(opt.KeyPrefix), Gzip(opt.Gzip), MaxBufferedSpans(opt.MaxBufferedSpans)}
+	if opt.FlushInterval > 0 {
+		options = append(options, FlushInterval(opt.FlushInterval))
+	}
+	return options
+}
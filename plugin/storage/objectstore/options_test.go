@@ -0,0 +1,67 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jaegertracing/jaeger/pkg/config"
+)
+
+func TestOptionsWithFlags(t *testing.T) {
+	opts := &Options{}
+	v, command := config.Viperize(opts.AddFlags)
+	command.ParseFlags([]string{
+		"--object-store.endpoint=http://127.0.0.1:9000",
+		"--object-store.bucket=traces",
+		"--object-store.region=eu-west-1",
+		"--object-store.force-path-style=true",
+		"--object-store.access-key-id=key",
+		"--object-store.secret-access-key=secret",
+		"--object-store.key-prefix=jaeger/",
+		"--object-store.flush-interval=30s",
+		"--object-store.max-buffered-spans=100",
+		"--object-store.gzip=true",
+	})
+	opts.InitFromViper(v)
+
+	assert.Equal(t, S3Config{
+		Endpoint:        "http://127.0.0.1:9000",
+		Bucket:          "traces",
+		Region:          "eu-west-1",
+		ForcePathStyle:  true,
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	}, opts.S3)
+	assert.Equal(t, "jaeger/", opts.KeyPrefix)
+	assert.Equal(t, 30*time.Second, opts.FlushInterval)
+	assert.Equal(t, 100, opts.MaxBufferedSpans)
+	assert.True(t, opts.Gzip)
+}
+
+func TestFlagDefaults(t *testing.T) {
+	opts := &Options{}
+	v, command := config.Viperize(opts.AddFlags)
+	command.ParseFlags([]string{})
+	opts.InitFromViper(v)
+
+	assert.Equal(t, S3Config{Region: defaultRegion}, opts.S3)
+	assert.Equal(t, defaultFlushInterval, opts.FlushInterval)
+	assert.Equal(t, defaultMaxBufferedSpans, opts.MaxBufferedSpans)
+	assert.False(t, opts.Gzip)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ ObjectStore = &S3Store{}
+
+func TestS3Store_putObject(t *testing.T) {
+	var method, path, authorization string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, authorization = r.Method, r.URL.Path, r.Header.Get("Authorization")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "traces",
+		Region:          "us-east-1",
+		ForcePathStyle:  true,
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.PutObject(context.Background(), "year=2020/service=frontend/1-1.jsonl", []byte("{}\n")))
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/traces/year=2020/service=frontend/1-1.jsonl", path)
+	assert.Contains(t, authorization, "Credential=key/")
+	assert.Equal(t, "{}\n", string(body))
+}
+
+func TestS3Store_putObjectError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{Endpoint: server.URL, Bucket: "traces", Region: "us-east-1", ForcePathStyle: true, AccessKeyID: "key", SecretAccessKey: "secret"})
+	require.NoError(t, err)
+	assert.Error(t, store.PutObject(context.Background(), "key", []byte("{}\n")))
+}
+
+func TestNewS3Store_bucketRequired(t *testing.T) {
+	store, err := NewS3Store(S3Config{})
+	assert.Nil(t, store)
+	assert.Equal(t, errBucketRequired, err)
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"go.uber.org/zap"
+
+	"github.com/jaegertracing/jaeger/model"
+)
+
+const (
+	defaultFlushInterval    = time.Minute
+	defaultMaxBufferedSpans = 10000
+)
+
+// ObjectStore uploads objects to an S3-compatible object store.
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, body []byte) error
+}
+
+// partition identifies spans stored in one object.
+type partition struct {
+	service string
+	hour    time.Time
+}
+
+// SpanWriter buffers spans and uploads them as JSON lines objects partitioned by time and service.
+// Implements spanstore.Writer
+type SpanWriter struct {
+	store            ObjectStore
+	logger           *zap.Logger
+	marshaller       *jsonpb.Marshaler
+	keyPrefix        string
+	flushInterval    time.Duration
+	maxBufferedSpans int
+	gzip             bool
+	timeNow          func() time.Time
+
+	mux      sync.Mutex
+	buffered int
+	spans    map[partition][]*model.Span
+	sequence uint64
+	// failures of periodic uploads, reported by the next Flush
+	failures []string
+
+	// flushMux serializes uploads, so that Flush returns only after uploads in progress.
+	flushMux sync.Mutex
+
+	stop      chan struct{}
+	stopped   sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// WriterOption is a function that sets some option on the SpanWriter.
+type WriterOption func(w *SpanWriter)
+
+// KeyPrefix sets the prefix of object keys.
+func KeyPrefix(prefix string) WriterOption {
+	return func(w *SpanWriter) {
+		w.keyPrefix = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// FlushInterval sets how often buffered spans are uploaded.
+func FlushInterval(interval time.Duration) WriterOption {
+	return func(w *SpanWriter) {
+		w.flushInterval = interval
+	}
+}
+
+// MaxBufferedSpans sets the number of buffered spans which triggers an upload before the flush interval elapses.
+func MaxBufferedSpans(maxSpans int) WriterOption {
+	return func(w *SpanWriter) {
+		w.maxBufferedSpans = maxSpans
+	}
+}
+
+// Gzip controls whether objects are gzip compressed.
+func Gzip(enabled bool) WriterOption {
+	return func(w *SpanWriter) {
+		w.gzip = enabled
+	}
+}
+
+// NewSpanWriter creates a SpanWriter which periodically uploads buffered spans until it is closed.
+func NewSpanWriter(store ObjectStore, logger *zap.Logger, options ...WriterOption) *SpanWriter {
+	w := &SpanWriter{
+		store:            store,
+		logger:           logger,
+		marshaller:       &jsonpb.Marshaler{},
+		flushInterval:    defaultFlushInterval,
+		maxBufferedSpans: defaultMaxBufferedSpans,
+		timeNow:          time.Now,
+		spans:            make(map[partition][]*model.Span),
+		stop:             make(chan struct{}),
+	}
+	for _, option := range options {
+		option(w)
+	}
+	w.start()
+	return w
+}
+
+func (w *SpanWriter) start() {
+	ticker := time.NewTicker(w.flushInterval)
+	w.stopped.Add(1)
+	go func() {
+		defer w.stopped.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.flushPeriodically()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// WriteSpan buffers the span and uploads buffered spans if the buffer is full.
+func (w *SpanWriter) WriteSpan(span *model.Span) error {
+	key := partition{hour: span.StartTime.UTC().Truncate(time.Hour)}
+	if span.Process != nil {
+		key.service = span.Process.ServiceName
+	}
+	w.mux.Lock()
+	w.spans[key] = append(w.spans[key], span)
+	w.buffered++
+	full := w.maxBufferedSpans > 0 && w.buffered >= w.maxBufferedSpans
+	w.mux.Unlock()
+	if full {
+		w.flushMux.Lock()
+		defer w.flushMux.Unlock()
+		return uploadError(w.upload(context.Background()))
+	}
+	return nil
+}
+
+// Flush uploads buffered spans, one object per service and hour of span start time.
+// Spans of objects which failed to upload are dropped. Flush also fails if a periodic
+// upload failed since the previous Flush, so that no span written before Flush is lost silently.
+func (w *SpanWriter) Flush(ctx context.Context) error {
+	w.flushMux.Lock()
+	defer w.flushMux.Unlock()
+	failures := w.upload(ctx)
+	w.mux.Lock()
+	failures = append(w.failures, failures...)
+	w.failures = nil
+	w.mux.Unlock()
+	return uploadError(failures)
+}
+
+// flushPeriodically uploads buffered spans and keeps failures for the next Flush.
+func (w *SpanWriter) flushPeriodically() {
+	w.flushMux.Lock()
+	defer w.flushMux.Unlock()
+	failures := w.upload(context.Background())
+	if len(failures) == 0 {
+		return
+	}
+	w.logger.Error("Failed to upload spans", zap.Error(uploadError(failures)))
+	w.mux.Lock()
+	w.failures = append(w.failures, failures...)
+	w.mux.Unlock()
+}
+
+// upload uploads buffered spans and returns failures of objects, it must be called with flushMux held.
+func (w *SpanWriter) upload(ctx context.Context) []string {
+	w.mux.Lock()
+	spans := w.spans
+	w.spans = make(map[partition][]*model.Span)
+	w.buffered = 0
+	w.mux.Unlock()
+
+	var failures []string
+	for p, partitionSpans := range spans {
+		body, err := w.encode(partitionSpans)
+		if err == nil {
+			err = w.store.PutObject(ctx, w.objectKey(p), body)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("service %q: %v", p.service, err))
+		}
+	}
+	return failures
+}
+
+func uploadError(failures []string) error {
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to upload %d objects: %s", len(failures), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// objectKey returns the key of an object partitioned by time and service,
+// e.g. prefix/year=2020/month=05/day=01/hour=13/service=frontend/1588338000000000000-1.jsonl.gz.
+// The service name is escaped so that it is always a single path segment.
+func (w *SpanWriter) objectKey(p partition) string {
+	w.mux.Lock()
+	w.sequence++
+	sequence := w.sequence
+	w.mux.Unlock()
+	key := fmt.Sprintf("year=%04d/month=%02d/day=%02d/hour=%02d/service=%s/%d-%d.jsonl",
+		p.hour.Year(), p.hour.Month(), p.hour.Day(), p.hour.Hour(), url.PathEscape(p.service), w.timeNow().UnixNano(), sequence)
+	if w.gzip {
+		key += ".gz"
+	}
+	if w.keyPrefix != "" {
+		key = w.keyPrefix + "/" + key
+	}
+	return key
+}
+
+// encode marshals spans as JSON lines, compressed if gzip is enabled.
+func (w *SpanWriter) encode(spans []*model.Span) ([]byte, error) {
+	var lines bytes.Buffer
+	for _, span := range spans {
+		if err := w.marshaller.Marshal(&lines, span); err != nil {
+			return nil, err
+		}
+		lines.WriteByte('\n')
+	}
+	if !w.gzip {
+		return lines.Bytes(), nil
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(lines.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// Close stops periodic uploads and uploads buffered spans.
+func (w *SpanWriter) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		w.stopped.Wait()
+		err = w.Flush(context.Background())
+	})
+	return err
+}
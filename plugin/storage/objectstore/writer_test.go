@@ -0,0 +1,202 @@
+// Copyright (c) 2020 The Jaeger Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/storage/spanstore"
+)
+
+var _ spanstore.Writer = &SpanWriter{}
+var _ io.Closer = &SpanWriter{}
+
+type mockObjectStore struct {
+	mux     sync.Mutex
+	objects map[string][]byte
+	err     error
+}
+
+func (s *mockObjectStore) setErr(err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.err = err
+}
+
+func (s *mockObjectStore) PutObject(ctx context.Context, key string, body []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.objects == nil {
+		s.objects = make(map[string][]byte)
+	}
+	s.objects[key] = body
+	return nil
+}
+
+func (s *mockObjectStore) keys() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var keys []string
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func fixedTime(t time.Time) WriterOption {
+	return func(w *SpanWriter) {
+		w.timeNow = func() time.Time { return t }
+	}
+}
+
+func testSpan(service string, spanID uint64, startTime time.Time) *model.Span {
+	return &model.Span{
+		TraceID:   model.NewTraceID(1, 2),
+		SpanID:    model.NewSpanID(spanID),
+		StartTime: startTime,
+		Process:   &model.Process{ServiceName: service},
+	}
+}
+
+func decodeSpans(t *testing.T, body []byte) []*model.Span {
+	var spans []*model.Span
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		span := &model.Span{}
+		require.NoError(t, jsonpb.UnmarshalString(scanner.Text(), span))
+		spans = append(spans, span)
+	}
+	require.NoError(t, scanner.Err())
+	return spans
+}
+
+func TestSpanWriter_partitionedObjectsUploadedOnClose(t *testing.T) {
+	store := &mockObjectStore{}
+	now := time.Date(2020, 5, 1, 14, 0, 0, 0, time.UTC)
+	w := NewSpanWriter(store, zap.NewNop(), KeyPrefix("traces/"), fixedTime(now))
+
+	start := time.Date(2020, 5, 1, 13, 30, 0, 0, time.UTC)
+	require.NoError(t, w.WriteSpan(testSpan("frontend", 1, start)))
+	require.NoError(t, w.WriteSpan(testSpan("frontend", 2, start.Add(10*time.Minute))))
+	require.NoError(t, w.WriteSpan(testSpan("frontend", 3, start.Add(time.Hour))))
+	require.NoError(t, w.WriteSpan(testSpan("backend", 4, start)))
+	assert.Empty(t, store.keys(), "spans are buffered until flush")
+
+	require.NoError(t, w.Close())
+	keys := store.keys()
+	require.Len(t, keys, 3)
+	assert.Regexp(t, `^traces/year=2020/month=05/day=01/hour=13/service=backend/1588341600000000000-\d+\.jsonl$`, keys[0])
+	assert.Regexp(t, `^traces/year=2020/month=05/day=01/hour=13/service=frontend/1588341600000000000-\d+\.jsonl$`, keys[1])
+	assert.Regexp(t, `^traces/year=2020/month=05/day=01/hour=14/service=frontend/1588341600000000000-\d+\.jsonl$`, keys[2])
+
+	spans := decodeSpans(t, store.objects[keys[1]])
+	require.Len(t, spans, 2)
+	assert.Equal(t, model.NewSpanID(1), spans[0].SpanID)
+	assert.Equal(t, model.NewSpanID(2), spans[1].SpanID)
+	assert.Equal(t, "frontend", spans[0].Process.ServiceName)
+}
+
+func TestSpanWriter_serviceNameEscaped(t *testing.T) {
+	store := &mockObjectStore{}
+	w := NewSpanWriter(store, zap.NewNop())
+	require.NoError(t, w.WriteSpan(testSpan("team/frontend app", 1, time.Now())))
+	require.NoError(t, w.Close())
+
+	keys := store.keys()
+	require.Len(t, keys, 1)
+	assert.Regexp(t, `^year=\d{4}/month=\d{2}/day=\d{2}/hour=\d{2}/service=team%2Ffrontend%20app/\d+-\d+\.jsonl$`, keys[0])
+}
+
+func TestSpanWriter_gzip(t *testing.T) {
+	store := &mockObjectStore{}
+	w := NewSpanWriter(store, zap.NewNop(), Gzip(true))
+	require.NoError(t, w.WriteSpan(testSpan("frontend", 1, time.Now())))
+	require.NoError(t, w.Close())
+
+	keys := store.keys()
+	require.Len(t, keys, 1)
+	assert.Regexp(t, `^year=\d{4}/month=\d{2}/day=\d{2}/hour=\d{2}/service=frontend/\d+-\d+\.jsonl\.gz$`, keys[0])
+	gz, err := gzip.NewReader(bytes.NewReader(store.objects[keys[0]]))
+	require.NoError(t, err)
+	var body bytes.Buffer
+	_, err = body.ReadFrom(gz)
+	require.NoError(t, err)
+	assert.Len(t, decodeSpans(t, body.Bytes()), 1)
+}
+
+func TestSpanWriter_maxBufferedSpans(t *testing.T) {
+	store := &mockObjectStore{}
+	w := NewSpanWriter(store, zap.NewNop(), MaxBufferedSpans(2))
+	defer w.Close()
+
+	require.NoError(t, w.WriteSpan(testSpan("frontend", 1, time.Now())))
+	assert.Empty(t, store.keys())
+	require.NoError(t, w.WriteSpan(testSpan("frontend", 2, time.Now())))
+	assert.Len(t, store.keys(), 1)
+}
+
+func TestSpanWriter_periodicFlush(t *testing.T) {
+	store := &mockObjectStore{}
+	w := NewSpanWriter(store, zap.NewNop(), FlushInterval(time.Millisecond))
+	defer w.Close()
+
+	require.NoError(t, w.WriteSpan(testSpan("frontend", 1, time.Now())))
+	assert.Eventually(t, func() bool {
+		return len(store.keys()) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestSpanWriter_uploadError(t *testing.T) {
+	store := &mockObjectStore{err: errors.New("access denied")}
+	w := NewSpanWriter(store, zap.NewNop())
+	require.NoError(t, w.WriteSpan(testSpan("frontend", 1, time.Now())))
+	err := w.Close()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `service "frontend": access denied`)
+	assert.NoError(t, w.Close(), "spans are uploaded once")
+}
+
+func TestSpanWriter_flushReportsPeriodicUploadFailures(t *testing.T) {
+	store := &mockObjectStore{err: errors.New("access denied")}
+	w := NewSpanWriter(store, zap.NewNop(), FlushInterval(time.Hour))
+	defer w.Close()
+
+	require.NoError(t, w.WriteSpan(testSpan("frontend", 1, time.Now())))
+	w.flushPeriodically()
+	store.setErr(nil)
+	err := w.Flush(context.Background())
+	require.Error(t, err, "spans lost by the periodic upload are reported")
+	assert.Contains(t, err.Error(), `service "frontend": access denied`)
+	assert.NoError(t, w.Flush(context.Background()))
+}